@@ -15,6 +15,13 @@ type Config struct {
 	// SUBSCRIBER specifies JWT verification config for subscribers.
 	SUBSCRIBER ConfigJWT `envPrefix:"SUBSCRIBER_"`
 
+	// ANONYMOUS specifies whether subscribers without a token may receive public updates.
+	ANONYMOUS bool `env:"ANONYMOUS" envDefault:"false"`
+
+	// ANONYMOUS_TOPICS restricts anonymous subscriptions to a set of topic selectors, newline delimited.
+	// Anonymous subscribers may subscribe to any topic if empty.
+	ANONYMOUS_TOPICS string `env:"ANONYMOUS_TOPICS" envDefault:""`
+
 	// METRICS specifies listen interface for prometheus metrics.
 	// i.e. http://localhost:9090/metrics
 	METRICS string `env:"METRICS" envDefault:":9090"`
@@ -22,9 +29,56 @@ type Config struct {
 	// HUB_COUNT specifies to how many hubs messages should be sharded.
 	HUB_COUNT int `env:"HUB_COUNT" envDefault:"16"`
 
-	// CACHE_SIZE_MB specifies the size of the message cache in megabytes
+	// CACHE_SIZE_MB specifies the size of the message cache in megabytes.
+	// A message is stored once per retained topic, once under * and once per matching selector
+	// subscribed to recently, so each of these copies counts toward the cache size.
 	CACHE_SIZE_MB int `env:"CACHE_SIZE_MB" envDefault:"256"`
 
+	// CACHE_DIR specifies a directory in which to persist the message cache.
+	// The message cache is held in memory if empty.
+	CACHE_DIR string `env:"CACHE_DIR" envDefault:""`
+
+	// CACHE_SNAPSHOT specifies a file to which recent topics, retained messages and the in-memory
+	// message cache are written on stop and from which they are restored on start. Disabled if empty.
+	CACHE_SNAPSHOT string `env:"CACHE_SNAPSHOT" envDefault:""`
+
+	// RETENTION specifies per-topic retention rules, newline delimited.
+	// Each rule is a topic selector followed by any of the following options:
+	//   age=<duration>  discard messages older than duration (i.e. 24h)
+	//   count=<n>       retain at most n messages per topic (count=0 retains none)
+	//   always          retain messages even if the topic has no recent subscribers
+	// The first matching rule applies. i.e. "/orders/{id} age=24h always"
+	RETENTION string `env:"RETENTION" envDefault:""`
+
+	// TRANSPORT specifies how messages are distributed between the nodes of a cluster.
+	// Messages are only delivered to subscribers of the node to which they are published if empty.
+	//   local     nodes running in the same process
+	//   peer      nodes listed in TRANSPORT_PEERS or discovered from TRANSPORT_PEERS_SRV, over HTTP
+	//   redis     nodes connected to the Redis server at TRANSPORT_URL
+	//   nats      nodes connected to the NATS JetStream server at TRANSPORT_URL
+	//   postgres  nodes reading the outbox table of the PostgreSQL database at TRANSPORT_URL
+	TRANSPORT string `env:"TRANSPORT" envDefault:""`
+
+	// TRANSPORT_URL specifies the address of the server for brokered transports.
+	// i.e. redis://localhost:6379/0, nats://localhost:4222 or postgres://localhost:5432/app
+	TRANSPORT_URL string `env:"TRANSPORT_URL" envDefault:""`
+
+	// TRANSPORT_HISTORY specifies the number of messages retained by the transport for replay.
+	// The peer transport remembers as many message IDs to pass each message on at most once.
+	TRANSPORT_HISTORY int `env:"TRANSPORT_HISTORY" envDefault:"10000"`
+
+	// TRANSPORT_PEERS specifies the base URLs of the other nodes for the peer transport, newline delimited.
+	// i.e. http://mercure-2:8001
+	TRANSPORT_PEERS string `env:"TRANSPORT_PEERS" envDefault:""`
+
+	// TRANSPORT_PEERS_SRV specifies a DNS SRV record resolving to the nodes for the peer transport.
+	// The record is resolved again every 30 seconds. Nodes may resolve to themselves.
+	// i.e. _mercure._tcp.mercure.default.svc.cluster.local
+	TRANSPORT_PEERS_SRV string `env:"TRANSPORT_PEERS_SRV" envDefault:""`
+
+	// TRANSPORT_KEY specifies the secret shared by all nodes to authenticate peer transport streams.
+	TRANSPORT_KEY string `env:"TRANSPORT_KEY" envDefault:""`
+
 	// DEBUG specifies whether to print invalid JWTs for investigation.
 	DEBUG bool `env:"DEBUG" envDefault:"false"`
 }
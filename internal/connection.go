@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"slices"
 	"sync"
+	"sync/atomic"
 )
 
 var connectionPool = sync.Pool{
@@ -15,50 +17,102 @@ var connectionPool = sync.Pool{
 	},
 }
 
-func newConnection(topics []string) (c *connection) {
+func newConnection(topics []string, claims *topicMatcher, payload any) (c *connection) {
 	c = connectionPool.Get().(*connection)
 	c.id = uuidv7()
 	c.topics = topics
+	c.claims = claims
+	c.payload = payload
 	c.closed = false
 	return
 }
 
+// connection is a subscriber registered to one or more hubs. It is closed by the first hub to
+// find it not keeping up and returned to the pool once every hub has unregistered it.
 type connection struct {
-	id     string
-	send   chan *message
-	topics []string
-	closed bool
+	id      string
+	send    chan *message
+	topics  []string
+	claims  *topicMatcher
+	payload any
+	closed  bool
+	mutex   sync.Mutex
+	refs    atomic.Int32
 }
 
 func (c *connection) Announce(h Hub, active bool) {
 	for _, topic := range c.topics {
-		b, _ := json.Marshal(c.toSubscription(topic, active))
+		sub := c.toSubscription(topic, active)
+		b, _ := json.Marshal(sub)
 		h.Broadcast(newMessage(
 			"Subscription",
-			[]string{subscriptionTopic},
+			[]string{sub.ID},
 			string(b),
 		))
 	}
 }
 
+// firehose indicates whether the connection is subscribed to every topic.
+func (c *connection) firehose() bool {
+	return slices.Contains(c.topics, topicAll)
+}
+
+// authorized indicates whether the connection may receive the message.
+// Private messages require the subscriber's claims to match at least one of the message's topics.
+func (c *connection) authorized(msg *message) bool {
+	return !msg.Private || c.claims.Match(msg.Topics...)
+}
+
+// trySend sends a message to the connection without blocking.
+// Returns false if the connection is not keeping up. Messages to closed connections are dropped.
+func (c *connection) trySend(msg *message) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return true
+	}
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// close closes the send channel. Returns false if the connection was already closed.
 func (c *connection) close() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	if c.closed {
 		return false
 	}
 	c.closed = true
 	close(c.send)
+	return true
+}
+
+// release closes the connection and returns it to the pool once it has been unregistered from
+// every hub to which it was registered.
+func (c *connection) release() {
+	if c.refs.Add(-1) > 0 {
+		return
+	}
+	c.close()
 	c.send = make(chan *message, 256)
 	connectionPool.Put(c)
-	return true
 }
 
 func (c *connection) toSubscription(topic string, active bool) subscription {
+	payload := c.payload
+	if payload == nil {
+		payload = make(map[string]any)
+	}
 	return subscription{
 		ID:         fmt.Sprintf("/.well-known/mercure/subscriptions/%s/%s", url.QueryEscape(topic), url.QueryEscape(c.id)),
 		Type:       "Subscription",
 		Topic:      topic,
 		Subscriber: c.id,
 		Active:     active,
-		Payload:    make(map[string]any),
+		Payload:    payload,
 	}
 }
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// fieldEscaper removes characters that can not be represented in single line event fields.
+var fieldEscaper = strings.NewReplacer("\r", "", "\n", "", "\x00", "")
+
+// appendEvent appends a server-sent event to dst per the HTML event stream format.
+// Data is split on CRLF, CR and LF into one data field per line. Line terminators are removed
+// from the id and event fields along with NUL which causes clients to ignore the id.
+// Nothing is appended if all fields are empty.
+func appendEvent(dst []byte, id, typ string, retry uint64, data string) []byte {
+	n := len(dst)
+	if len(id) > 0 {
+		dst = appendField(dst, "id", fieldEscaper.Replace(id))
+	}
+	if len(typ) > 0 {
+		dst = appendField(dst, "event", fieldEscaper.Replace(typ))
+	}
+	if retry > 0 {
+		dst = strconv.AppendUint(append(dst, "retry: "...), retry, 10)
+		dst = append(dst, '\n')
+	}
+	for len(data) > 0 {
+		i := strings.IndexAny(data, "\r\n")
+		if i < 0 {
+			dst = appendField(dst, "data", data)
+			break
+		}
+		dst = appendField(dst, "data", data[:i])
+		if data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			i++
+		}
+		data = data[i+1:]
+		if len(data) == 0 {
+			dst = appendField(dst, "data", "")
+		}
+	}
+	if len(dst) == n {
+		return dst
+	}
+	return append(dst, '\n')
+}
+
+func appendField(dst []byte, name, value string) []byte {
+	dst = append(dst, name...)
+	dst = append(dst, ": "...)
+	dst = append(dst, value...)
+	return append(dst, '\n')
+}
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// apiError is a problem details response body.
+// See https://datatracker.ietf.org/doc/html/rfc9457
+type apiError struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// writeError writes a structured JSON error response.
+func writeError(w http.ResponseWriter, status int, detail string) {
+	b, _ := json.Marshal(apiError{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	})
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// writeUnauthorized writes a 401 response with a bearer challenge.
+// The challenge indicates an invalid token if the request contained one.
+func writeUnauthorized(w http.ResponseWriter, r *http.Request) {
+	if len(jwtToken(r)) > 0 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="mercure", error="invalid_token"`)
+		writeError(w, 401, "Invalid or expired token")
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="mercure"`)
+	writeError(w, 401, "Missing token")
+}
+
+// writeForbidden writes a 403 response for a valid token lacking the required claims.
+func writeForbidden(w http.ResponseWriter, detail string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="mercure", error="insufficient_scope"`)
+	writeError(w, 403, detail)
+}
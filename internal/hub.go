@@ -3,6 +3,7 @@ package internal
 import (
 	"context"
 	"maps"
+	"slices"
 	"sync"
 )
 
@@ -12,10 +13,26 @@ type Hub interface {
 	Unregister(*connection)
 	Broadcast(*message)
 	Connections() map[*connection]bool
+	Subscriber(topic, id string) *connection
+	Subscribers(topic string) []*connection
 }
 
+// hubIndex determines which kinds of subscriptions a hub indexes.
+type hubIndex uint8
+
+const (
+	indexExact hubIndex = 1 << iota
+	indexSelector
+
+	indexAll = indexExact | indexSelector
+)
+
 type hub struct {
+	firehose      map[*connection]bool
+	ids           map[string]*connection
+	index         hubIndex
 	metrics       *metrics
+	selectors     *selectorIndex[*connection]
 	subscriptions map[string]map[*connection]bool
 	register      chan *connection
 	unregister    chan *connection
@@ -24,9 +41,13 @@ type hub struct {
 	mutex sync.RWMutex
 }
 
-func newHub(m *metrics) *hub {
+func newHub(m *metrics, index hubIndex) *hub {
 	return &hub{
+		firehose:      make(map[*connection]bool),
+		ids:           make(map[string]*connection),
+		index:         index,
 		metrics:       m,
+		selectors:     newSelectorIndex[*connection](),
 		subscriptions: make(map[string]map[*connection]bool),
 		register:      make(chan *connection),
 		unregister:    make(chan *connection),
@@ -41,7 +62,24 @@ func (h *hub) Run(ctx context.Context) {
 			return
 		case conn := <-h.register:
 			h.mutex.Lock()
+			h.ids[conn.id] = conn
+			if conn.firehose() {
+				if h.index&indexSelector > 0 {
+					h.firehose[conn] = true
+				}
+				h.mutex.Unlock()
+				break
+			}
 			for _, topic := range conn.topics {
+				if isSelector(topic) {
+					if h.index&indexSelector > 0 {
+						h.selectors.Add(topic, conn)
+					}
+					continue
+				}
+				if h.index&indexExact == 0 {
+					continue
+				}
 				if _, ok := h.subscriptions[topic]; !ok {
 					h.subscriptions[topic] = make(map[*connection]bool)
 				}
@@ -50,36 +88,56 @@ func (h *hub) Run(ctx context.Context) {
 			h.mutex.Unlock()
 		case conn := <-h.unregister:
 			h.mutex.Lock()
+			if h.ids[conn.id] == conn {
+				delete(h.ids, conn.id)
+			}
+			delete(h.firehose, conn)
 			for _, topic := range conn.topics {
+				if isSelector(topic) {
+					h.selectors.Remove(topic, conn)
+					continue
+				}
 				delete(h.subscriptions[topic], conn)
 			}
-			conn.close()
 			h.mutex.Unlock()
+			conn.release()
 		case msg := <-h.broadcast:
 			h.mutex.RLock()
 			for _, t := range msg.Topics {
 				if connections, ok := h.subscriptions[t]; ok {
 					for conn := range connections {
-						select {
-						case conn.send <- msg:
-						default:
-							if conn.close() {
-								h.metrics.Terminate()
-							}
-						}
+						h.send(conn, msg)
 					}
 				}
+				h.selectors.Match(t, func(conn *connection) {
+					h.send(conn, msg)
+				})
+			}
+			for conn := range h.firehose {
+				h.send(conn, msg)
 			}
 			h.mutex.RUnlock()
 		}
 	}
 }
 
+func (h *hub) send(conn *connection, msg *message) {
+	if !conn.authorized(msg) {
+		return
+	}
+	if !conn.trySend(msg) && conn.close() {
+		h.metrics.Terminate()
+	}
+}
+
 func (h *hub) Broadcast(msg *message) {
 	h.broadcast <- msg
 }
 
+// Register registers a connection. Each registration must be matched by a call to Unregister
+// before the connection is returned to the pool.
 func (h *hub) Register(conn *connection) {
+	conn.refs.Add(1)
 	h.register <- conn
 }
 
@@ -93,6 +151,41 @@ func (h *hub) Connections() map[*connection]bool {
 	for _, m := range h.subscriptions {
 		maps.Copy(m2, m)
 	}
+	h.selectors.Each(func(conn *connection) {
+		m2[conn] = true
+	})
+	maps.Copy(m2, h.firehose)
 	h.mutex.RUnlock()
 	return m2
 }
+
+// Subscriber returns the connection of a subscriber to a topic.
+func (h *hub) Subscriber(topic, id string) *connection {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	conn, ok := h.ids[id]
+	if !ok || !slices.Contains(conn.topics, topic) {
+		return nil
+	}
+	return conn
+}
+
+// Subscribers returns the connections subscribed to a topic.
+func (h *hub) Subscribers(topic string) (res []*connection) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	var conns map[*connection]bool
+	switch {
+	case topic == topicAll:
+		conns = h.firehose
+	case isSelector(topic):
+		conns = h.selectors.Get(topic)
+	default:
+		conns = h.subscriptions[topic]
+	}
+	res = make([]*connection, 0, len(conns))
+	for conn := range conns {
+		res = append(res, conn)
+	}
+	return
+}
@@ -4,19 +4,25 @@ import (
 	"context"
 	"hash/crc32"
 	"maps"
+	"slices"
+	"sync/atomic"
 )
 
 type hubMulti struct {
-	hubs []*hub
+	hubs      []*hub
+	selectors *hub
+
+	selectorConns atomic.Int64
 }
 
 func newHubMulti(hubCount int, m *metrics) (h *hubMulti) {
 	hubCount = max(hubCount, 1)
 	h = &hubMulti{
-		hubs: make([]*hub, hubCount),
+		hubs:      make([]*hub, hubCount),
+		selectors: newHub(m, indexSelector),
 	}
 	for i := range h.hubs {
-		h.hubs[i] = newHub(m)
+		h.hubs[i] = newHub(m, indexExact)
 	}
 	return
 }
@@ -25,24 +31,55 @@ func (h *hubMulti) Run(ctx context.Context) {
 	for _, h := range h.hubs {
 		go h.Run(ctx)
 	}
+	go h.selectors.Run(ctx)
 }
 
+// Register registers a connection to the hub for each of its exact topics and to the selector
+// hub if it has any selectors. Connections subscribed to every topic are only registered to the
+// selector hub to ensure they receive each message exactly once.
 func (h *hubMulti) Register(c *connection) {
+	if c.firehose() {
+		h.selectorConns.Add(1)
+		h.selectors.Register(c)
+		return
+	}
 	for _, topic := range c.topics {
-		h.hubs[h.hash(topic)].Register(c)
+		if !isSelector(topic) {
+			h.hubs[h.hash(topic)].Register(c)
+		}
+	}
+	if slices.ContainsFunc(c.topics, isSelector) {
+		h.selectorConns.Add(1)
+		h.selectors.Register(c)
 	}
 }
 
 func (h *hubMulti) Unregister(c *connection) {
+	if c.firehose() {
+		h.selectors.Unregister(c)
+		h.selectorConns.Add(-1)
+		return
+	}
 	for _, topic := range c.topics {
-		h.hubs[h.hash(topic)].Unregister(c)
+		if !isSelector(topic) {
+			h.hubs[h.hash(topic)].Unregister(c)
+		}
+	}
+	if slices.ContainsFunc(c.topics, isSelector) {
+		h.selectors.Unregister(c)
+		h.selectorConns.Add(-1)
 	}
 }
 
+// Broadcast sends a message to the hub for each of its topics and, when any selector
+// subscriptions exist, once to the selector hub.
 func (h *hubMulti) Broadcast(m *message) {
 	for _, topic := range m.Topics {
 		h.hubs[h.hash(topic)].Broadcast(m)
 	}
+	if h.selectorConns.Load() > 0 {
+		h.selectors.Broadcast(m)
+	}
 }
 
 func (h *hubMulti) Connections() map[*connection]bool {
@@ -50,9 +87,26 @@ func (h *hubMulti) Connections() map[*connection]bool {
 	for _, h := range h.hubs {
 		maps.Copy(m2, h.Connections())
 	}
+	maps.Copy(m2, h.selectors.Connections())
 	return m2
 }
 
+func (h *hubMulti) Subscriber(topic, id string) *connection {
+	return h.route(topic).Subscriber(topic, id)
+}
+
+func (h *hubMulti) Subscribers(topic string) []*connection {
+	return h.route(topic).Subscribers(topic)
+}
+
+// route returns the hub to which subscriptions to a topic are registered.
+func (h *hubMulti) route(topic string) *hub {
+	if isSelector(topic) {
+		return h.selectors
+	}
+	return h.hubs[h.hash(topic)]
+}
+
 func (h *hubMulti) hash(topic string) int {
 	return int(crc32.ChecksumIEEE([]byte(topic))) % len(h.hubs)
 }
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"container/list"
+	"sync"
+)
+
+type idIndexItem struct {
+	id  string
+	cur uint64
+}
+
+// idIndex maps the IDs of cached messages to their sequence numbers so that Last-Event-ID resolves
+// to an exact position in the cache. Entries older than the cache horizon are pruned on add.
+type idIndex struct {
+	ids   map[string]*list.Element
+	items *list.List
+	mutex sync.RWMutex
+}
+
+func newIDIndex() *idIndex {
+	return &idIndex{
+		ids:   make(map[string]*list.Element),
+		items: list.New(),
+	}
+}
+
+// Add adds an ID at a cursor, pruning entries older than horizon.
+// Returns false if the ID is already present.
+func (x *idIndex) Add(id string, cur, horizon uint64) bool {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	for el := x.items.Front(); el != nil && el.Value.(idIndexItem).cur < horizon; el = x.items.Front() {
+		delete(x.ids, x.items.Remove(el).(idIndexItem).id)
+	}
+	if _, ok := x.ids[id]; ok {
+		return false
+	}
+	x.ids[id] = x.items.PushBack(idIndexItem{id, cur})
+	return true
+}
+
+// Remove removes an ID from the index.
+func (x *idIndex) Remove(id string) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	if el, ok := x.ids[id]; ok {
+		x.items.Remove(el)
+		delete(x.ids, id)
+	}
+}
+
+// Get returns the cursor for an ID.
+func (x *idIndex) Get(id string) (cur uint64, ok bool) {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	el, ok := x.ids[id]
+	if !ok {
+		return
+	}
+	return el.Value.(idIndexItem).cur, true
+}
+
+// Len returns the number of IDs in the index.
+func (x *idIndex) Len() int {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	return x.items.Len()
+}
@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIDIndex(t *testing.T) {
+	x := newIDIndex()
+	assert.True(t, x.Add("a", 1, 0))
+	assert.True(t, x.Add("b", 2, 0))
+	assert.False(t, x.Add("a", 3, 0))
+	x.Remove("a")
+	_, ok := x.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, x.Len())
+	assert.True(t, x.Add("c", 3, 3))
+	cur, ok := x.Get("c")
+	assert.True(t, ok)
+	assert.EqualValues(t, 3, cur)
+	assert.Equal(t, 1, x.Len())
+
+	// Messages to topics which are not cached are not indexed
+	s := testServer(Config{})
+	for range 1000 {
+		assert.True(t, s.sequence(newMessage("", []string{"uncached"}, "data"), ""))
+	}
+	assert.Equal(t, 0, s.ids.Len())
+}
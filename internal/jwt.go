@@ -84,11 +84,12 @@ type tokenClaims struct {
 	Mercure struct {
 		Publish   []string `json:"publish"`
 		Subscribe []string `json:"subscribe"`
+		Payload   any      `json:"payload"`
 	} `json:"mercure"`
 	jwt.RegisteredClaims
 }
 
-func jwtTokenClaims(r *http.Request, keys []any, debug bool) *tokenClaims {
+func jwtToken(r *http.Request) string {
 	tokenStr := r.Header.Get("Authorization")
 	if parts := strings.Split(tokenStr, " "); len(parts) == 2 {
 		tokenStr = parts[1]
@@ -102,6 +103,11 @@ func jwtTokenClaims(r *http.Request, keys []any, debug bool) *tokenClaims {
 	if tokenStr == "" {
 		tokenStr = r.Form.Get("authorization")
 	}
+	return tokenStr
+}
+
+func jwtTokenClaims(r *http.Request, keys []any, debug bool) *tokenClaims {
+	tokenStr := jwtToken(r)
 	if tokenStr == "" {
 		return nil
 	}
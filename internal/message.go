@@ -2,15 +2,21 @@ package internal
 
 import (
 	"encoding/json"
-	"fmt"
 	"io"
+	"time"
 )
 
 type message struct {
-	ID     string
-	Type   string
-	Topics []string
-	Data   string
+	ID      string
+	Type    string
+	Topics  []string
+	Data    string
+	Private bool      `json:",omitempty"`
+	Retain  bool      `json:",omitempty"`
+	Retry   uint64    `json:",omitempty"`
+	Expires time.Time `json:",omitzero"`
+
+	seq uint64
 }
 
 func newMessage(msgType string, topics []string, data string) (m *message) {
@@ -23,23 +29,19 @@ func newMessage(msgType string, topics []string, data string) (m *message) {
 }
 
 func (msg *message) WriteTo(w io.Writer) (in int64, err error) {
-	var out []byte
-	if len(msg.ID) > 0 {
-		out = fmt.Appendf(out, "id: %v\n", msg.ID)
-	}
-	if len(msg.Type) > 0 {
-		out = fmt.Appendf(out, "type: %v\n", msg.Type)
-	}
-	if len(msg.Data) > 0 {
-		out = fmt.Appendf(out, "data: %s\n", msg.Data)
-	}
+	out := appendEvent(nil, msg.ID, msg.Type, msg.Retry, msg.Data)
 	if len(out) == 0 {
 		return
 	}
-	n, err := w.Write(append(out, []byte("\n")...))
+	n, err := w.Write(out)
 	return int64(n), err
 }
 
+// expired indicates whether the time to live of the message has elapsed.
+func (msg *message) expired(now time.Time) bool {
+	return !msg.Expires.IsZero() && !msg.Expires.After(now)
+}
+
 func (msg *message) ToJson() (out []byte) {
 	out, _ = json.Marshal(msg)
 	return
@@ -48,7 +50,3 @@ func (msg *message) ToJson() (out []byte) {
 func (msg *message) FromJson(in []byte) {
 	json.Unmarshal(in, msg)
 }
-
-func (msg *message) timestamp() uint64 {
-	return msgIDtimestamp(msg.ID)
-}
@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmaxmax/go-sse"
+)
+
+func TestMessageWriteTo(t *testing.T) {
+	msg := &message{ID: "a", Type: "b", Retry: 100, Data: "{\n  \"c\": 1\r\n}"}
+	var buf bytes.Buffer
+	_, err := msg.WriteTo(&buf)
+	require.Nil(t, err)
+	assert.Equal(t, "id: a\nevent: b\nretry: 100\ndata: {\ndata:   \"c\": 1\ndata: }\n\n", buf.String())
+	buf.Reset()
+	_, err = (&message{}).WriteTo(&buf)
+	require.Nil(t, err)
+	assert.Equal(t, 0, buf.Len())
+}
+
+func FuzzMessageWriteTo(f *testing.F) {
+	f.Add("urn:uuid:0196e2a4-5b1a-7000-8000-000000000000", "update", uint64(0), "test-data")
+	f.Add("1", "", uint64(3000), "{\n  \"a\": [\n    1\n  ]\n}\n")
+	f.Add("a\nb", "c\r\nd", uint64(1), "\r\r\n\n")
+	f.Add("", "", uint64(0), " leading space\r")
+	f.Add("\x00", "", uint64(0), "data: nested\n\nid: nested")
+	f.Fuzz(func(t *testing.T, id, typ string, retry uint64, data string) {
+		if len(data) == 0 {
+			return
+		}
+		msg := &message{ID: id, Type: typ, Retry: retry, Data: data}
+		var buf bytes.Buffer
+		_, err := msg.WriteTo(&buf)
+		require.Nil(t, err)
+		var events []sse.Event
+		for e, err := range sse.Read(&buf, &sse.ReadConfig{MaxEventSize: 1 << 20}) {
+			require.Nil(t, err)
+			events = append(events, e)
+		}
+		require.Len(t, events, 1)
+		assert.Equal(t, fieldEscaper.Replace(id), events[0].LastEventID)
+		assert.Equal(t, fieldEscaper.Replace(typ), events[0].Type)
+		assert.Equal(t, strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(data), events[0].Data)
+	})
+}
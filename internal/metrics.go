@@ -6,14 +6,13 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/logbn/mvfifo"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type metrics struct {
-	cache  *mvfifo.Cache
+	cache  Store
 	ctx    context.Context
 	listen string
 	server *http.Server
@@ -30,14 +29,15 @@ type metrics struct {
 	subscriptions_total    prometheus.Counter
 }
 
-func NewMetrics(listen string, cache *mvfifo.Cache) *metrics {
-	return &metrics{listen: listen, cache: cache}
+func NewMetrics(listen string) *metrics {
+	return &metrics{listen: listen}
 }
 
-func (m *metrics) Start(ctx context.Context) {
+func (m *metrics) Start(ctx context.Context, cache Store) {
 	if m == nil || m.listen == "" {
 		return
 	}
+	m.cache = cache
 	log.Printf("Starting metrics on %s", m.listen)
 	m.server = &http.Server{
 		Addr:    m.listen,
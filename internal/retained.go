@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+	"sync"
+	"time"
+)
+
+// retainedIndex holds the latest retained message per topic, independent of the message cache.
+type retainedIndex struct {
+	msgs  map[string]*message
+	mutex sync.RWMutex
+}
+
+func newRetainedIndex() *retainedIndex {
+	return &retainedIndex{msgs: make(map[string]*message)}
+}
+
+// Set retains a message for each of its topics. A message without data clears the topics instead.
+func (x *retainedIndex) Set(msg *message) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	for _, topic := range msg.Topics {
+		if len(msg.Data) == 0 {
+			delete(x.msgs, topic)
+		} else {
+			x.msgs[topic] = msg
+		}
+	}
+}
+
+// setTopic retains a message for a single topic.
+func (x *retainedIndex) setTopic(topic string, msg *message) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	x.msgs[topic] = msg
+}
+
+// Match returns the unexpired retained messages for a set of topics or selectors ordered by
+// sequence. Messages retained for several matching topics are returned once.
+func (x *retainedIndex) Match(topics []string, now time.Time) (res []*message) {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	if slices.ContainsFunc(topics, isSelector) {
+		matcher := newTopicMatcher(topics)
+		for topic, msg := range x.msgs {
+			if matcher.Match(topic) && !msg.expired(now) {
+				res = append(res, msg)
+			}
+		}
+	} else {
+		for _, topic := range topics {
+			if msg, ok := x.msgs[topic]; ok && !msg.expired(now) {
+				res = append(res, msg)
+			}
+		}
+	}
+	slices.SortFunc(res, func(a, b *message) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	return slices.CompactFunc(res, func(a, b *message) bool {
+		return a.ID == b.ID
+	})
+}
+
+// All returns an iterator over the retained messages by topic.
+func (x *retainedIndex) All() iter.Seq2[string, *message] {
+	return func(yield func(string, *message) bool) {
+		x.mutex.RLock()
+		defer x.mutex.RUnlock()
+		for topic, msg := range x.msgs {
+			if !yield(topic, msg) {
+				return
+			}
+		}
+	}
+}
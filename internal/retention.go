@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retentionRule specifies how messages published to topics matching a selector are retained.
+type retentionRule struct {
+	selector *topicMatcher
+	maxAge   time.Duration
+	maxCount int
+	always   bool
+}
+
+// retention is an ordered list of retention rules. The first matching rule applies.
+type retention []retentionRule
+
+var retentionDefault = retentionRule{maxCount: -1}
+
+// parseRetention parses newline delimited retention rules of the form
+//
+//	<selector> [age=<duration>] [count=<n>] [always]
+func parseRetention(cfg string) (res retention, err error) {
+	for line := range strings.Lines(cfg) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		rule := retentionRule{
+			selector: newTopicMatcher(fields[:1]),
+			maxCount: -1,
+		}
+		for _, opt := range fields[1:] {
+			key, val, _ := strings.Cut(opt, "=")
+			switch key {
+			case "age":
+				rule.maxAge, err = time.ParseDuration(val)
+			case "count":
+				rule.maxCount, err = strconv.Atoi(val)
+				if rule.maxCount < 0 {
+					err = fmt.Errorf("negative count")
+				}
+			case "always":
+				rule.always = true
+			default:
+				err = fmt.Errorf("unknown option")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Invalid retention option %q for %s: %w", opt, fields[0], err)
+			}
+		}
+		res = append(res, rule)
+	}
+	return
+}
+
+// rule returns the first rule matching the topic.
+func (r retention) rule(topic string) retentionRule {
+	for _, rule := range r {
+		if rule.selector.Match(topic) {
+			return rule
+		}
+	}
+	return retentionDefault
+}
+
+// retain indicates whether a message published to a topic should be stored.
+func (r retention) retain(topic string, recent bool) bool {
+	rule := r.rule(topic)
+	return rule.maxCount != 0 && (recent || rule.always)
+}
+
+// retainAny indicates whether a message published to the topics may be stored under a recently
+// subscribed selector matching them, such as the reserved topic. The decision follows the rules
+// for the message's own topics rather than the rule for the selector.
+func (r retention) retainAny(topics []string) bool {
+	for _, topic := range topics {
+		if r.rule(topic).maxCount != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// IterAfter returns an iterator over the retained messages for a topic after a cursor.
+// Messages older than the maximum age and all but the latest maximum count are skipped.
+func (r retention) IterAfter(store Store, topic string, cur uint64, now time.Time) iter.Seq2[uint64, []byte] {
+	rule := r.rule(topic)
+	if rule.maxAge > 0 {
+		cur = max(cur, timeCursor(now.Add(-rule.maxAge)))
+	}
+	if rule.maxCount < 0 {
+		return store.IterAfter(topic, cur)
+	}
+	return func(yield func(uint64, []byte) bool) {
+		var n, total int
+		for range store.IterAfter(topic, cur) {
+			total++
+		}
+		for c, val := range store.IterAfter(topic, cur) {
+			if n++; n <= total-rule.maxCount {
+				continue
+			}
+			if !yield(c, val) {
+				return
+			}
+		}
+	}
+}
+
+// Horizon returns the cursor before which retained history for a topic may be incomplete.
+// It is the latest of the oldest cursor in the store, the maximum age and the most recent
+// message discarded by the maximum count.
+func (r retention) Horizon(store Store, topic string, now time.Time) (cur uint64) {
+	cur, _ = store.First()
+	rule := r.rule(topic)
+	if rule.maxAge > 0 {
+		cur = max(cur, timeCursor(now.Add(-rule.maxAge)))
+	}
+	if rule.maxCount < 0 {
+		return
+	}
+	var curs []uint64
+	for c := range store.IterAfter(topic, cur) {
+		curs = append(curs, c)
+	}
+	if n := len(curs) - rule.maxCount; n > 0 {
+		cur = max(cur, curs[n-1])
+	}
+	return
+}
+
+// Filter returns an iterator over the messages to any of the topics retained by the rules for
+// their own topics, such as messages replayed from a transport in publish order. Messages older
+// than the maximum age and all but the latest maximum count of messages to each topic are skipped.
+// The age of messages without a UUIDv7 ID is unknown. Returns true if history was cut by a maximum
+// age or a non-zero maximum count, in which case it may be incomplete.
+func (r retention) Filter(topics []string, msgs iter.Seq[*message], now time.Time) (iter.Seq[*message], bool) {
+	var all []*message
+	if msgs != nil {
+		all = slices.Collect(msgs)
+	}
+	matcher := newTopicMatcher(topics)
+	keep := make([]bool, len(all))
+	counts := map[string]int{}
+	var cut bool
+	for i := len(all) - 1; i >= 0; i-- {
+		for _, topic := range all[i].Topics {
+			if !matcher.Match(topic) {
+				continue
+			}
+			rule := r.rule(topic)
+			if rule.maxCount == 0 {
+				continue
+			}
+			if ts := msgIDtimestamp(all[i].ID); rule.maxAge > 0 && ts > 0 && ts < timeCursor(now.Add(-rule.maxAge)) {
+				cut = true
+				continue
+			}
+			if rule.maxCount > 0 && counts[topic] >= rule.maxCount {
+				cut = true
+				continue
+			}
+			counts[topic]++
+			keep[i] = true
+		}
+	}
+	return func(yield func(*message) bool) {
+		for i, msg := range all {
+			if keep[i] && !yield(msg) {
+				return
+			}
+		}
+	}, cut
+}
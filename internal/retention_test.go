@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetention(t *testing.T) {
+	r, err := parseRetention("/orders/{id} age=24h always\n\n/telemetry/{id} count=0\n/feed count=2\n")
+	require.Nil(t, err)
+	require.Len(t, r, 3)
+	assert.True(t, r.retain("/orders/1", false))
+	assert.False(t, r.retain("/telemetry/1", true))
+	assert.True(t, r.retain("/feed", true))
+	assert.False(t, r.retain("/feed", false))
+	assert.False(t, r.retain("other", false))
+	assert.True(t, r.retain("other", true))
+	assert.False(t, r.retainAny([]string{"/telemetry/1"}))
+	assert.True(t, r.retainAny([]string{"/telemetry/1", "other"}))
+
+	now := time.Now()
+	s := newStoreMemory(1 << 20)
+	for i, age := range []time.Duration{48 * time.Hour, 25 * time.Hour, 2 * time.Hour, time.Minute} {
+		cur := timeCursor(now.Add(-age))
+		s.Add("/orders/1", cur, fmt.Appendf(nil, "order-%d", i))
+		s.Add("/feed", cur, fmt.Appendf(nil, "feed-%d", i))
+	}
+	var vals []string
+	for _, val := range r.IterAfter(s, "/orders/1", 1, now) {
+		vals = append(vals, string(val))
+	}
+	assert.Equal(t, []string{"order-2", "order-3"}, vals)
+	vals = nil
+	for _, val := range r.IterAfter(s, "/feed", 1, now) {
+		vals = append(vals, string(val))
+	}
+	assert.Equal(t, []string{"feed-2", "feed-3"}, vals)
+	assert.Equal(t, timeCursor(now.Add(-24*time.Hour)), r.Horizon(s, "/orders/1", now))
+	assert.Equal(t, timeCursor(now.Add(-25*time.Hour)), r.Horizon(s, "/feed", now))
+	assert.Equal(t, timeCursor(now.Add(-48*time.Hour)), r.Horizon(s, "other", now))
+
+	var msgs []*message
+	for i, age := range []time.Duration{48 * time.Hour, 2 * time.Hour, time.Minute, time.Second} {
+		for _, topic := range []string{"/orders/1", "/telemetry/1", "/feed"} {
+			u, _ := uuid.NewV7AtTime(now.Add(-age))
+			msgs = append(msgs, &message{ID: "urn:uuid:" + u.String(), Topics: []string{topic}, Data: fmt.Sprintf("%s-%d", topic, i)})
+		}
+	}
+	filter := func(topics ...string) (data []string, cut bool) {
+		filtered, cut := r.Filter(topics, slices.Values(msgs), now)
+		for msg := range filtered {
+			data = append(data, msg.Data)
+		}
+		return data, cut
+	}
+	data, cut := filter("/orders/{id}", "/telemetry/{id}")
+	assert.Equal(t, []string{"/orders/1-1", "/orders/1-2", "/orders/1-3"}, data)
+	assert.True(t, cut)
+	data, cut = filter("/feed")
+	assert.Equal(t, []string{"/feed-2", "/feed-3"}, data)
+	assert.True(t, cut)
+	data, cut = filter("/telemetry/1")
+	assert.Nil(t, data)
+	assert.False(t, cut)
+	data, cut = filter("other", "/feed")
+	assert.Len(t, data, 2)
+	assert.True(t, cut)
+	filtered, cut := r.Filter([]string{"/feed"}, nil, now)
+	assert.False(t, cut)
+	for range filtered {
+		t.Fatal("Unexpected message")
+	}
+
+	for _, cfg := range []string{"/feed count=-1", "/feed age=soon", "/feed forever"} {
+		_, err = parseRetention(cfg)
+		assert.NotNil(t, err, cfg)
+	}
+}
@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"strings"
+
+	"github.com/yosida95/uritemplate"
+)
+
+// topicAll is the reserved topic selector matching every topic.
+const topicAll = "*"
+
+// isSelector indicates whether a topic is a URI template or the reserved topic selector rather
+// than an exact topic.
+func isSelector(topic string) bool {
+	return topic == topicAll || strings.ContainsRune(topic, '{')
+}
+
+// topicMatcher matches topics against a set of topic selectors such as JWT claims.
+type topicMatcher struct {
+	all   bool
+	exact map[string]bool
+	tpls  []*uritemplate.Template
+}
+
+func newTopicMatcher(selectors []string) *topicMatcher {
+	m := &topicMatcher{exact: make(map[string]bool)}
+	for _, sel := range selectors {
+		switch {
+		case sel == topicAll:
+			m.all = true
+		case isSelector(sel):
+			if tpl, err := uritemplate.New(sel); err == nil {
+				m.tpls = append(m.tpls, tpl)
+			}
+		default:
+			m.exact[sel] = true
+		}
+	}
+	return m
+}
+
+// Match indicates whether any of the topics match any of the selectors.
+func (m *topicMatcher) Match(topics ...string) bool {
+	if m == nil {
+		return false
+	}
+	if m.all {
+		return true
+	}
+	for _, topic := range topics {
+		if m.exact[topic] {
+			return true
+		}
+	}
+	for _, topic := range topics {
+		for _, tpl := range m.tpls {
+			if tpl.Match(topic) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// selectorIndex indexes URI template topic selectors in a prefix trie keyed by the complete
+// path segments of each template's literal prefix. Matching a topic walks the trie once and
+// only evaluates templates whose literal prefix is compatible with the topic.
+// Members are the values registered to each selector, i.e. subscribed connections.
+type selectorIndex[T comparable] struct {
+	root *selectorNode[T]
+	size int
+}
+
+type selectorNode[T comparable] struct {
+	children  map[string]*selectorNode[T]
+	selectors map[string]*selector[T]
+}
+
+type selector[T comparable] struct {
+	tpl     *uritemplate.Template
+	members map[T]bool
+}
+
+func newSelectorIndex[T comparable]() *selectorIndex[T] {
+	return &selectorIndex[T]{root: newSelectorNode[T]()}
+}
+
+func newSelectorNode[T comparable]() *selectorNode[T] {
+	return &selectorNode[T]{
+		children:  make(map[string]*selectorNode[T]),
+		selectors: make(map[string]*selector[T]),
+	}
+}
+
+// Add adds a member to a selector.
+func (idx *selectorIndex[T]) Add(topic string, member T) {
+	node := idx.root
+	for _, seg := range selectorSegments(topic) {
+		child, ok := node.children[seg]
+		if !ok {
+			child = newSelectorNode[T]()
+			node.children[seg] = child
+		}
+		node = child
+	}
+	sel, ok := node.selectors[topic]
+	if !ok {
+		tpl, err := uritemplate.New(topic)
+		if err != nil {
+			return
+		}
+		sel = &selector[T]{tpl: tpl, members: make(map[T]bool)}
+		node.selectors[topic] = sel
+	}
+	if !sel.members[member] {
+		sel.members[member] = true
+		idx.size++
+	}
+}
+
+// Get returns the members of a selector.
+func (idx *selectorIndex[T]) Get(topic string) map[T]bool {
+	node := idx.root
+	for _, seg := range selectorSegments(topic) {
+		child, ok := node.children[seg]
+		if !ok {
+			return nil
+		}
+		node = child
+	}
+	if sel, ok := node.selectors[topic]; ok {
+		return sel.members
+	}
+	return nil
+}
+
+// Remove removes a member from a selector, pruning empty nodes.
+func (idx *selectorIndex[T]) Remove(topic string, member T) {
+	segs := selectorSegments(topic)
+	path := make([]*selectorNode[T], 0, len(segs)+1)
+	node := idx.root
+	path = append(path, node)
+	for _, seg := range segs {
+		child, ok := node.children[seg]
+		if !ok {
+			return
+		}
+		node = child
+		path = append(path, node)
+	}
+	sel, ok := node.selectors[topic]
+	if !ok || !sel.members[member] {
+		return
+	}
+	delete(sel.members, member)
+	idx.size--
+	if len(sel.members) > 0 {
+		return
+	}
+	delete(node.selectors, topic)
+	for i := len(segs); i > 0; i-- {
+		if len(path[i].selectors) > 0 || len(path[i].children) > 0 {
+			break
+		}
+		delete(path[i-1].children, segs[i-1])
+	}
+}
+
+// Match calls fn for every member of a selector matching the topic.
+func (idx *selectorIndex[T]) Match(topic string, fn func(T)) {
+	if idx.size == 0 {
+		return
+	}
+	node := idx.root
+	rest := topic
+	for {
+		for _, sel := range node.selectors {
+			if sel.tpl.Match(topic) == nil {
+				continue
+			}
+			for member := range sel.members {
+				fn(member)
+			}
+		}
+		i := strings.IndexByte(rest, '/')
+		if i < 0 {
+			return
+		}
+		child, ok := node.children[rest[:i]]
+		if !ok {
+			return
+		}
+		node = child
+		rest = rest[i+1:]
+	}
+}
+
+// Each calls fn for every member in the index.
+func (idx *selectorIndex[T]) Each(fn func(T)) {
+	var walk func(*selectorNode[T])
+	walk = func(node *selectorNode[T]) {
+		for _, sel := range node.selectors {
+			for member := range sel.members {
+				fn(member)
+			}
+		}
+		for _, child := range node.children {
+			walk(child)
+		}
+	}
+	walk(idx.root)
+}
+
+// Len returns the number of selector memberships in the index.
+func (idx *selectorIndex[T]) Len() int {
+	return idx.size
+}
+
+// selectorSegments returns the complete path segments of a template's literal prefix.
+func selectorSegments(topic string) []string {
+	prefix := topic
+	if i := strings.IndexByte(topic, '{'); i >= 0 {
+		prefix = topic[:i]
+	}
+	segs := strings.Split(prefix, "/")
+	return segs[:len(segs)-1]
+}
@@ -1,29 +1,36 @@
 package internal
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"log"
 	"net/http"
+	"net/url"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/benbjohnson/clock"
-	"github.com/logbn/expset"
-	"github.com/logbn/mvfifo"
 	"github.com/yosida95/uritemplate"
 )
 
 var (
+	historyPath       = "/.well-known/mercure/history"
+	historyLimit      = 100
+	historyLimitMax   = 1000
+	subscriptionsPath = "/.well-known/mercure/subscriptions"
 	subscriptionTopic = "/.well-known/mercure/subscriptions/topic/subscriber"
 	pingPeriod        = 30 * time.Second
 )
 
 type server struct {
-	cache          *mvfifo.Cache
+	anonTopics     *topicMatcher
+	cache          *storeTTL
 	cfg            Config
 	clock          clock.Clock
 	ctx            context.Context
@@ -31,32 +38,50 @@ type server struct {
 	done           chan bool
 	httpClient     *http.Client
 	hub            Hub
+	ids            *idIndex
 	metrics        *metrics
 	mutex          sync.RWMutex
 	pubJwksRefresh time.Duration
 	pubKeys        []any
 	pubKeysJwks    []any
-	recentTopics   *expset.Set[string]
+	recentTopics   *topicSet
+	retained       *retainedIndex
+	retention      retention
+	seq            uint64
+	seqMutex       sync.Mutex
 	server         *http.Server
 	subJwksRefresh time.Duration
 	subKeys        []any
 	subKeysJwks    []any
+	transport      Transport
 }
 
 func NewServer(cfg Config) *server {
-	var cache = mvfifo.NewCache(mvfifo.WithMaxSizeBytes(max(cfg.CACHE_SIZE_MB, 16) << 20))
+	clk := clock.New()
+	var cache *storeTTL
+	if len(cfg.CACHE_DIR) == 0 {
+		maxBytes := max(cfg.CACHE_SIZE_MB, 16) << 20
+		cache = newStoreTTL(newStoreMemory(maxBytes), maxBytes, clk)
+	}
 	var m *metrics
 	if len(cfg.METRICS) > 0 {
-		m = NewMetrics(cfg.METRICS, cache)
+		m = NewMetrics(cfg.METRICS)
+	}
+	var anonTopics *topicMatcher
+	if topics := strings.Fields(cfg.ANONYMOUS_TOPICS); len(topics) > 0 {
+		anonTopics = newTopicMatcher(topics)
 	}
 	return &server{
+		anonTopics:   anonTopics,
 		cache:        cache,
 		cfg:          cfg,
-		clock:        clock.New(),
+		clock:        clk,
 		httpClient:   &http.Client{Timeout: 5 * time.Second},
 		hub:          newHubMulti(cfg.HUB_COUNT, m),
+		ids:          newIDIndex(),
 		metrics:      m,
-		recentTopics: expset.New[string](),
+		recentTopics: newTopicSet(clk),
+		retained:     newRetainedIndex(),
 	}
 }
 
@@ -78,9 +103,34 @@ func (s *server) Start(ctx context.Context) (err error) {
 		return fmt.Errorf("No subscriber keys available")
 	}
 	s.subJwksRefresh = time.Duration(max(int(maxage), 60)) * time.Second
+	if s.retention, err = parseRetention(s.cfg.RETENTION); err != nil {
+		return
+	}
+	if s.transport, err = newTransport(s.cfg); err != nil {
+		return
+	}
+	if len(s.cfg.CACHE_DIR) > 0 {
+		maxBytes := max(s.cfg.CACHE_SIZE_MB, 16) << 20
+		disk, err := newStoreDisk(s.cfg.CACHE_DIR, maxBytes)
+		if err != nil {
+			return err
+		}
+		s.cache = newStoreTTL(disk, maxBytes, s.clock)
+		s.cache.Purge()
+		s.reindex(disk.All())
+	}
+	if len(s.cfg.CACHE_SNAPSHOT) > 0 {
+		if err = s.readSnapshot(); err != nil {
+			return
+		}
+	}
 	s.done = make(chan bool)
 	s.startJwksRefresh()
 	go s.hub.Run(s.ctx)
+	go s.cache.Run(s.ctx)
+	if s.transport != nil {
+		go s.transport.Run(s.ctx, s.receive)
+	}
 	s.server = &http.Server{
 		Addr:    s.cfg.LISTEN,
 		Handler: s,
@@ -98,7 +148,7 @@ func (s *server) Start(ctx context.Context) (err error) {
 		case <-s.done:
 		}
 	}()
-	s.metrics.Start(ctx)
+	s.metrics.Start(ctx, s.cache)
 	return nil
 }
 
@@ -111,6 +161,21 @@ func (s *server) Stop() {
 	defer cancel()
 	s.server.Shutdown(timeout)
 	s.metrics.Stop()
+	if s.transport != nil {
+		if err := s.transport.Close(); err != nil {
+			log.Print(err)
+		}
+	}
+	if len(s.cfg.CACHE_SNAPSHOT) > 0 {
+		if err := s.writeSnapshot(); err != nil {
+			log.Print(err)
+		}
+	}
+	if len(s.cfg.CACHE_DIR) > 0 {
+		if err := s.cache.Close(); err != nil {
+			log.Print(err)
+		}
+	}
 	s.ctxCancel()
 	s.ctx = nil
 }
@@ -132,7 +197,20 @@ func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		default:
 			w.WriteHeader(405)
 		}
-	case "/.well-known/mercure/subscriptions":
+	case historyPath:
+		switch strings.ToUpper(r.Method) {
+		case "GET":
+			s.history(w, r)
+		default:
+			w.WriteHeader(405)
+		}
+	case transportPath:
+		if h, ok := s.transport.(http.Handler); ok {
+			h.ServeHTTP(w, r)
+		} else {
+			w.WriteHeader(404)
+		}
+	case subscriptionsPath:
 		switch strings.ToUpper(r.Method) {
 		case "GET":
 			s.list(w, r)
@@ -140,32 +218,157 @@ func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(405)
 		}
 	default:
-		w.WriteHeader(404)
+		if !strings.HasPrefix(r.URL.Path, subscriptionsPath+"/") {
+			w.WriteHeader(404)
+			return
+		}
+		switch strings.ToUpper(r.Method) {
+		case "GET":
+			s.listTopic(w, r)
+		default:
+			w.WriteHeader(405)
+		}
 	}
 }
 
 func (s *server) publish(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
-	msg := newMessage(
-		r.Form.Get("type"),
-		s.verifyPublish(r, r.Form["topic"]),
-		r.Form.Get("data"),
-	)
-	if len(msg.Topics) == 0 {
-		w.WriteHeader(403)
+	topics, claims := s.verifyPublish(r, r.Form["topic"])
+	if claims == nil {
+		writeUnauthorized(w, r)
 		return
 	}
-	for _, topic := range msg.Topics {
-		if s.recentTopics.Has(topic) {
-			s.cache.Add(topic, msg.timestamp(), msg.ToJson())
+	if len(r.Form["topic"]) == 0 {
+		writeError(w, 400, "Missing topic")
+		return
+	}
+	if len(topics) == 0 {
+		writeForbidden(w, "Not authorized to publish to any of the requested topics")
+		return
+	}
+	msg := newMessage(r.Form.Get("type"), topics, r.Form.Get("data"))
+	msg.Private = r.Form.Get("private") == "on"
+	msg.Retain = r.Form.Get("retain") == "on"
+	if retry := r.Form.Get("retry"); len(retry) > 0 {
+		n, err := strconv.ParseUint(retry, 10, 64)
+		if err != nil {
+			writeError(w, 400, "Invalid retry")
+			return
 		}
+		msg.Retry = n
+	}
+	if ttl := r.Form.Get("ttl"); len(ttl) > 0 {
+		n, err := strconv.ParseUint(ttl, 10, 32)
+		if err != nil || n == 0 {
+			writeError(w, 400, "Invalid ttl")
+			return
+		}
+		msg.Expires = s.clock.Now().Add(time.Duration(n) * time.Second)
+	}
+	var custom = r.Form.Get("id")
+	if len(custom) > 0 && !validID(custom) {
+		writeError(w, 400, "Invalid id")
+		return
+	}
+	if !s.sequence(msg, custom) {
+		writeError(w, 409, "Duplicate id")
+		return
+	}
+	if msg.Retain {
+		s.retained.Set(msg)
 	}
 	s.hub.Broadcast(msg)
+	if s.transport != nil {
+		if err := s.transport.Publish(msg); err != nil {
+			log.Print(err)
+		}
+	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Write([]byte(msg.ID))
 	s.metrics.Publish()
 }
 
+// sequence assigns the next sequence number to a message, replaces its ID with any custom ID
+// and stores it for replay. Sequence numbers are hub-wide, strictly increasing and never less
+// than the UUIDv7 timestamp of the message so that they remain ordered across restarts and
+// comparable to points in time. Returns false if the custom ID is a duplicate.
+func (s *server) sequence(msg *message, custom string) bool {
+	s.seqMutex.Lock()
+	defer s.seqMutex.Unlock()
+	msg.seq = max(s.seq+1, msgIDtimestamp(msg.ID))
+	if len(custom) > 0 {
+		msg.ID = custom
+	}
+	horizon, _ := s.cache.First()
+	if horizon == 0 {
+		horizon = msg.seq
+	}
+	if !s.ids.Add(msg.ID, msg.seq, horizon) {
+		return false
+	}
+	s.seq = msg.seq
+	var cached bool
+	for _, topic := range msg.Topics {
+		if s.retention.retain(topic, s.recentTopics.Has(topic)) {
+			s.cacheAdd(topic, msg)
+			cached = true
+		}
+	}
+	if s.recentTopics.Has(topicAll) && s.retention.retainAny(msg.Topics) {
+		s.cacheAdd(topicAll, msg)
+		cached = true
+	}
+	// Messages are also stored under the recent selectors they match for selector replay.
+	// Each copy counts toward the cache size, see CACHE_SIZE_MB.
+	if sels := s.recentTopics.Selectors(msg.Topics); len(sels) > 0 && s.retention.retainAny(msg.Topics) {
+		for _, sel := range sels {
+			s.cacheAdd(sel, msg)
+		}
+		cached = true
+	}
+	if !cached {
+		s.ids.Remove(msg.ID)
+	}
+	return true
+}
+
+// reindex rebuilds the ID index from the messages in a store and advances the sequence past the
+// latest stored cursor so that sequence numbers remain ordered across restarts.
+func (s *server) reindex(items iter.Seq2[string, storeItem]) {
+	s.seqMutex.Lock()
+	defer s.seqMutex.Unlock()
+	horizon, _ := s.cache.First()
+	for _, item := range items {
+		var msg message
+		msg.FromJson(item.val)
+		if len(msg.ID) > 0 {
+			s.ids.Add(msg.ID, item.cur, horizon)
+		}
+		s.seq = max(s.seq, item.cur)
+	}
+}
+
+// receive broadcasts a message published to another node, storing it for replay and retaining it
+// if it was published with the retain flag. Messages with an ID already in the cache are ignored.
+func (s *server) receive(msg *message) {
+	if !s.sequence(msg, "") {
+		return
+	}
+	if msg.Retain {
+		s.retained.Set(msg)
+	}
+	s.hub.Broadcast(msg)
+}
+
+// cacheAdd stores a message for replay. Messages with a time to live are purged once expired.
+func (s *server) cacheAdd(topic string, msg *message) {
+	if msg.Expires.IsZero() {
+		s.cache.Add(topic, msg.seq, msg.ToJson())
+	} else {
+		s.cache.AddTTL(topic, msg.seq, msg.ToJson(), msg.Expires)
+	}
+}
+
 func (s *server) options(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", s.cfg.CORS_ORIGINS)
 	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Last-Event-ID, Cache-Control")
@@ -174,9 +377,17 @@ func (s *server) options(w http.ResponseWriter, _ *http.Request) {
 
 func (s *server) subscribe(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
-	topics := r.Form["topic"]
-	topics, jwtExpires := s.verifySubscribe(r, topics)
+	topics, claims, jwtExpires := s.verifySubscribe(r, r.Form["topic"])
+	if claims == nil {
+		writeUnauthorized(w, r)
+		return
+	}
+	if len(r.Form["topic"]) == 0 {
+		writeError(w, 400, "Missing topic")
+		return
+	}
 	if len(topics) < 1 {
+		writeForbidden(w, "Not authorized to subscribe to any of the requested topics")
 		return
 	}
 	if jwtExpires < 1 {
@@ -185,7 +396,7 @@ func (s *server) subscribe(w http.ResponseWriter, r *http.Request) {
 	topics, err := s.normalize(topics)
 	if err != nil {
 		log.Print(err)
-		w.WriteHeader(400)
+		writeError(w, 400, err.Error())
 		return
 	}
 	for _, topic := range topics {
@@ -198,13 +409,27 @@ func (s *server) subscribe(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", s.cfg.CORS_ORIGINS)
 	w.Header().Set("Access-Control-Allow-Headers", "Authorization")
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Expose-Headers", "Last-Event-ID")
+	conn := newConnection(topics, newTopicMatcher(claims.Mercure.Subscribe), claims.Mercure.Payload)
+	// EventSource can not set headers on first connect so the query parameter is also accepted
 	lastEventID := r.Header.Get("Last-Event-ID")
-	lastEventCursor := msgIDtimestamp(lastEventID)
-	if lastEventCursor > 0 {
-		var msg = &message{}
-		for _, topic := range topics {
-			for _, data := range s.cache.IterAfter(topic, lastEventCursor) {
-				msg.FromJson(data)
+	if len(lastEventID) == 0 {
+		lastEventID = r.Form.Get("lastEventID")
+	}
+	now := s.clock.Now()
+	if len(lastEventID) > 0 {
+		var msgs iter.Seq[*message]
+		msgs, lastEventID = s.replayAfter(topics, lastEventID, now)
+		w.Header().Set("Last-Event-ID", lastEventID)
+		for msg := range msgs {
+			if conn.authorized(msg) {
+				msg.WriteTo(w)
+			}
+		}
+	}
+	if len(lastEventID) == 0 {
+		for _, msg := range s.retained.Match(topics, now) {
+			if conn.authorized(msg) {
 				msg.WriteTo(w)
 			}
 		}
@@ -212,7 +437,6 @@ func (s *server) subscribe(w http.ResponseWriter, r *http.Request) {
 	if _, err := w.Write([]byte(":\n")); err != nil {
 		return
 	}
-	conn := newConnection(topics)
 	conn.Announce(s.hub, true)
 	s.hub.Register(conn)
 	defer s.hub.Unregister(conn)
@@ -257,28 +481,123 @@ func (s *server) subscribe(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// cursor returns the position of a message ID in the cache.
+func (s *server) cursor(id string) uint64 {
+	cur, ok := s.ids.Get(id)
+	if !ok {
+		// Approximates the position of UUIDv7 IDs no longer in the index to the millisecond
+		cur = msgIDtimestamp(id)
+	}
+	return cur
+}
+
+// replayAfter returns an iterator over the messages to any of the topics published after the
+// message with the given ID along with the effective ID, which is "earliest" if history may be
+// incomplete. History retained by the transport takes precedence over the local cache and is
+// subject to the same retention rules.
+func (s *server) replayAfter(topics []string, id string, now time.Time) (iter.Seq[*message], string) {
+	if h, ok := s.transport.(History); ok {
+		msgs, ok := h.Replay(topics, id)
+		var cut bool
+		if ok {
+			msgs, cut = s.retention.Filter(topics, msgs, now)
+		}
+		if !ok || cut && id != lastEventEarliest {
+			// Instructs the client to refetch state since retained history is incomplete
+			id = lastEventEarliest
+			msgs, _ = h.Replay(topics, id)
+			msgs, _ = s.retention.Filter(topics, msgs, now)
+		}
+		return func(yield func(*message) bool) {
+			for msg := range msgs {
+				if !msg.expired(now) && !yield(msg) {
+					return
+				}
+			}
+		}, id
+	}
+	var cur uint64
+	if id != lastEventEarliest {
+		cur = s.cursor(id)
+		if s.missed(topics, cur, now) {
+			id = lastEventEarliest
+		}
+	}
+	msgs := s.replay(topics, cur, now)
+	if slices.ContainsFunc(topics, isSelector) {
+		// Copies stored under selectors are subject to the rules for the message's own topics
+		msgs, _ = s.retention.Filter(topics, msgs, now)
+	}
+	return msgs, id
+}
+
+// replay returns an iterator over the retained messages to any of the topics after a cursor in
+// publish order. Messages published to several topics are stored once per topic with the same
+// cursor and are yielded once.
+func (s *server) replay(topics []string, cur uint64, now time.Time) iter.Seq[*message] {
+	return func(yield func(*message) bool) {
+		iters := make([]iter.Seq2[uint64, []byte], len(topics))
+		for i, topic := range topics {
+			iters[i] = s.retention.IterAfter(s.cache, topic, cur, now)
+		}
+		var seen = map[string]bool{}
+		var seenCursor uint64
+		for cur, data := range storeMerge(iters...) {
+			var msg = &message{seq: cur}
+			msg.FromJson(data)
+			if cur != seenCursor {
+				clear(seen)
+				seenCursor = cur
+			}
+			if seen[msg.ID] || msg.expired(now) {
+				continue
+			}
+			seen[msg.ID] = true
+			if !yield(msg) {
+				return
+			}
+		}
+	}
+}
+
+// missed indicates whether any messages to the topics after the cursor may have been discarded.
+// Unknown event IDs are always considered missed.
+func (s *server) missed(topics []string, cur uint64, now time.Time) bool {
+	if cur == 0 {
+		return true
+	}
+	for _, topic := range topics {
+		if cur < s.retention.Horizon(s.cache, topic, now) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *server) normalize(topics []string) ([]string, error) {
 	for i := range topics {
-		t, err := uritemplate.New(topics[i])
-		if err != nil {
+		if _, err := uritemplate.New(topics[i]); err != nil {
 			return topics, fmt.Errorf("Invalid topic: %s", topics[i])
 		}
-		if t.Match(subscriptionTopic) != nil {
-			topics[i] = subscriptionTopic
-		}
 	}
 	return topics, nil
 }
 
-func (s *server) verifySubscribe(r *http.Request, topics []string) (res []string, jwtExpires time.Duration) {
-	claims := jwtTokenClaims(r, s.allSubKeys(), s.cfg.DEBUG)
+func (s *server) verifySubscribe(r *http.Request, topics []string) (res []string, claims *tokenClaims, jwtExpires time.Duration) {
+	claims = jwtTokenClaims(r, s.allSubKeys(), s.cfg.DEBUG)
 	if claims == nil {
+		if s.cfg.ANONYMOUS && jwtToken(r) == "" {
+			if res = s.verifyAnonymous(topics); len(res) > 0 {
+				claims = &tokenClaims{}
+			}
+		}
 		return
 	}
 	if claims.RegisteredClaims.ExpiresAt != nil {
 		jwtExpires = s.clock.Until(claims.RegisteredClaims.ExpiresAt.Truncate(time.Second))
 	}
-	all := slices.Contains(claims.Mercure.Subscribe, "*")
+	// Subscription to the reserved topic requires the reserved topic in the subscribe claim
+	all := slices.Contains(claims.Mercure.Subscribe, topicAll)
 	for _, t := range topics {
 		if all || slices.Contains(claims.Mercure.Subscribe, t) {
 			res = append(res, t)
@@ -287,13 +606,42 @@ func (s *server) verifySubscribe(r *http.Request, topics []string) (res []string
 	return
 }
 
-func (s *server) verifyPublish(r *http.Request, topics []string) (res []string) {
-	claims := jwtTokenClaims(r, s.allPubKeys(), s.cfg.DEBUG)
+// verifyAnonymous returns the topics to which a subscriber without a token may subscribe.
+// Anonymous subscribers have no subscribe claims so they only ever receive public updates.
+// The reserved topic and subscription events are never available to anonymous subscribers.
+func (s *server) verifyAnonymous(topics []string) (res []string) {
+	for _, t := range topics {
+		if t == topicAll || selectsSubscriptions(t) {
+			continue
+		}
+		if s.anonTopics == nil || s.anonTopics.Match(t) {
+			res = append(res, t)
+		}
+	}
+	return
+}
+
+// selectsSubscriptions indicates whether a topic selector may match subscription events.
+// Subscription events are published under their subscription IDs so any selector whose literal
+// prefix is compatible with the subscriptions path may match them.
+func selectsSubscriptions(topic string) bool {
+	i := strings.IndexByte(topic, '{')
+	if i < 0 {
+		return strings.HasPrefix(topic, subscriptionsPath+"/")
+	}
+	return strings.HasPrefix(topic[:i], subscriptionsPath) || strings.HasPrefix(subscriptionsPath, topic[:i])
+}
+
+func (s *server) verifyPublish(r *http.Request, topics []string) (res []string, claims *tokenClaims) {
+	claims = jwtTokenClaims(r, s.allPubKeys(), s.cfg.DEBUG)
 	if claims == nil {
 		return
 	}
-	all := slices.Contains(claims.Mercure.Publish, "*")
+	all := slices.Contains(claims.Mercure.Publish, topicAll)
 	for _, t := range topics {
+		if t == topicAll {
+			continue
+		}
 		if all || slices.Contains(claims.Mercure.Publish, t) {
 			res = append(res, t)
 		}
@@ -301,23 +649,152 @@ func (s *server) verifyPublish(r *http.Request, topics []string) (res []string)
 	return
 }
 
+// verifyList returns a matcher for the subscribe claims of the subscriber or nil if unauthorized.
+// Subscriptions are visible to subscribers authorized to receive their subscription events.
+func (s *server) verifyList(r *http.Request) *topicMatcher {
+	r.ParseForm()
+	claims := jwtTokenClaims(r, s.allSubKeys(), s.cfg.DEBUG)
+	if claims == nil {
+		return nil
+	}
+	return newTopicMatcher(claims.Mercure.Subscribe)
+}
+
 func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	auth := s.verifyList(r)
+	if auth == nil {
+		writeUnauthorized(w, r)
+		return
+	}
 	w.Header().Set("Content-Type", "application/ld+json")
 	list := subscriptionList{
-		Context:     "github.com/pantopic/mercure-lite",
+		Context:     subscriptionContext,
 		ID:          subscriptionTopic,
 		Type:        "Subscriptions",
 		LastEventID: uuidv7(),
 	}
 	for c := range s.hub.Connections() {
 		for _, topic := range c.topics {
-			list.Subscriptions = append(list.Subscriptions, c.toSubscription(topic, true))
+			if sub := c.toSubscription(topic, true); auth.Match(sub.ID) {
+				list.Subscriptions = append(list.Subscriptions, sub)
+			}
+		}
+	}
+	b, _ := json.Marshal(list)
+	w.Write(b)
+}
+
+// listTopic serves subscriptions to a topic or a single subscription to a topic by subscriber.
+// Path segments are query escaped as in [connection.toSubscription].
+func (s *server) listTopic(w http.ResponseWriter, r *http.Request) {
+	auth := s.verifyList(r)
+	if auth == nil {
+		writeUnauthorized(w, r)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), subscriptionsPath+"/"), "/")
+	if len(parts) > 2 {
+		w.WriteHeader(404)
+		return
+	}
+	for i := range parts {
+		part, err := url.QueryUnescape(parts[i])
+		if err != nil || len(part) == 0 {
+			w.WriteHeader(404)
+			return
+		}
+		parts[i] = part
+	}
+	topic := parts[0]
+	w.Header().Set("Content-Type", "application/ld+json")
+	if len(parts) == 2 {
+		conn := s.hub.Subscriber(topic, parts[1])
+		if conn == nil {
+			w.WriteHeader(404)
+			return
+		}
+		sub := conn.toSubscription(topic, true)
+		if !auth.Match(sub.ID) {
+			writeForbidden(w, "Not authorized to access this subscription")
+			return
+		}
+		sub.Context = subscriptionContext
+		b, _ := json.Marshal(sub)
+		w.Write(b)
+		return
+	}
+	list := subscriptionList{
+		Context:       subscriptionContext,
+		ID:            r.URL.EscapedPath(),
+		Type:          "Subscriptions",
+		LastEventID:   uuidv7(),
+		Subscriptions: []subscription{},
+	}
+	for _, c := range s.hub.Subscribers(topic) {
+		if sub := c.toSubscription(topic, true); auth.Match(sub.ID) {
+			list.Subscriptions = append(list.Subscriptions, sub)
 		}
 	}
 	b, _ := json.Marshal(list)
 	w.Write(b)
 }
 
+// historyPage is a page of retained messages. Next is the ID of the last message in the page
+// and is present only if more messages may follow.
+type historyPage struct {
+	Messages []*message `json:"messages"`
+	Next     string     `json:"next,omitempty"`
+}
+
+// history serves a page of retained messages to the requested topics after a message ID.
+// Authorization and the Last-Event-ID response header match those of [server.subscribe].
+func (s *server) history(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	topics, claims, _ := s.verifySubscribe(r, r.Form["topic"])
+	if claims == nil {
+		writeUnauthorized(w, r)
+		return
+	}
+	if len(r.Form["topic"]) == 0 {
+		writeError(w, 400, "Missing topic")
+		return
+	}
+	if len(topics) < 1 {
+		writeForbidden(w, "Not authorized to subscribe to any of the requested topics")
+		return
+	}
+	limit := historyLimit
+	if l := r.Form.Get("limit"); len(l) > 0 {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			writeError(w, 400, "Invalid limit")
+			return
+		}
+		limit = min(n, historyLimitMax)
+	}
+	after := r.Form.Get("after")
+	msgs, effective := s.replayAfter(topics, cmp.Or(after, lastEventEarliest), s.clock.Now())
+	if len(after) > 0 {
+		w.Header().Set("Last-Event-ID", effective)
+	}
+	auth := newTopicMatcher(claims.Mercure.Subscribe)
+	page := historyPage{Messages: []*message{}}
+	for msg := range msgs {
+		if msg.Private && !auth.Match(msg.Topics...) {
+			continue
+		}
+		if len(page.Messages) == limit {
+			page.Next = page.Messages[limit-1].ID
+			break
+		}
+		page.Messages = append(page.Messages, msg)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	b, _ := json.Marshal(page)
+	w.Write(b)
+}
+
 func (s *server) allPubKeys() []any {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const snapshotVersion = 1
+
+// snapshotRecord is a line of a snapshot file. The first line holds only the version.
+// Each subsequent line holds a recent topic, a retained message or a cached message with its cursor.
+type snapshotRecord struct {
+	Version int             `json:"version,omitempty"`
+	Recent  string          `json:"recent,omitempty"`
+	Expires time.Time       `json:"expires,omitzero"`
+	Retain  string          `json:"retain,omitempty"`
+	Topic   string          `json:"topic,omitempty"`
+	Cursor  uint64          `json:"cursor,omitempty"`
+	Message json.RawMessage `json:"message,omitempty"`
+}
+
+// writeSnapshot writes recent topics, retained messages and the contents of an in-memory cache
+// to the snapshot file as newline delimited JSON. The file is replaced atomically.
+func (s *server) writeSnapshot() (err error) {
+	tmp := s.cfg.CACHE_SNAPSHOT + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp)
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	enc.Encode(snapshotRecord{Version: snapshotVersion})
+	for topic, exp := range s.recentTopics.All() {
+		enc.Encode(snapshotRecord{Recent: topic, Expires: exp})
+	}
+	for topic, msg := range s.retained.All() {
+		enc.Encode(snapshotRecord{Retain: topic, Cursor: msg.seq, Message: msg.ToJson()})
+	}
+	if cache, ok := s.cache.Store.(*storeMemory); ok {
+		for topic, item := range cache.All() {
+			enc.Encode(snapshotRecord{Topic: topic, Cursor: item.cur, Message: item.val})
+		}
+	}
+	for topic, item := range s.cache.All() {
+		enc.Encode(snapshotRecord{Topic: topic, Expires: item.exp, Cursor: item.cur, Message: item.val})
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	return os.Rename(tmp, s.cfg.CACHE_SNAPSHOT)
+}
+
+// readSnapshot restores recent topics, retained messages and cached messages from the snapshot file.
+// Cached messages are skipped if the cache is persisted to disk since it is restored from disk.
+// Reading stops at the first incomplete or invalid line so that a truncated file restores
+// everything written before the truncation. A missing file is not an error.
+func (s *server) readSnapshot() (err error) {
+	f, err := os.Open(s.cfg.CACHE_SNAPSHOT)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	_, memory := s.cache.Store.(*storeMemory)
+	var version bool
+	var retained = map[string]*message{}
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			// The final line is incomplete or the file is exhausted
+			break
+		}
+		var rec snapshotRecord
+		if json.Unmarshal(line, &rec) != nil {
+			break
+		}
+		if !version {
+			if rec.Version != snapshotVersion {
+				return fmt.Errorf("Unsupported snapshot version %d", rec.Version)
+			}
+			version = true
+			continue
+		}
+		switch {
+		case len(rec.Recent) > 0:
+			if ttl := s.clock.Until(rec.Expires); ttl > 0 {
+				s.recentTopics.Add(rec.Recent, ttl)
+			}
+		case len(rec.Retain) > 0:
+			// Messages retained for several topics are restored as a single message
+			msg, ok := retained[string(rec.Message)]
+			if !ok {
+				msg = &message{seq: rec.Cursor}
+				msg.FromJson(rec.Message)
+				retained[string(rec.Message)] = msg
+			}
+			s.retained.setTopic(rec.Retain, msg)
+			s.seq = max(s.seq, rec.Cursor)
+		case len(rec.Topic) > 0 && rec.Cursor > 0 && memory:
+			var msg message
+			msg.FromJson(rec.Message)
+			if rec.Expires.IsZero() {
+				s.cache.Add(rec.Topic, rec.Cursor, rec.Message)
+			} else {
+				s.cache.AddTTL(rec.Topic, rec.Cursor, rec.Message, rec.Expires)
+			}
+			horizon, _ := s.cache.First()
+			s.ids.Add(msg.ID, rec.Cursor, horizon)
+			s.seq = max(s.seq, rec.Cursor)
+		}
+	}
+	return nil
+}
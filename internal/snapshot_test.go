@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	cfg := Config{CACHE_SNAPSHOT: filepath.Join(t.TempDir(), "snapshot.ndjson")}
+	s := testServer(cfg)
+	s.recentTopics.Add("a", time.Hour)
+	s.recentTopics.Add("b", time.Hour)
+	var ids []string
+	for _, topics := range [][]string{{"a"}, {"a", "b"}, {"b"}} {
+		msg := newMessage("", topics, strings.Join(topics, ","))
+		require.True(t, s.sequence(msg, ""))
+		ids = append(ids, msg.ID)
+	}
+	retained := newMessage("", []string{"a", "c"}, "retained")
+	require.True(t, s.sequence(retained, ""))
+	s.retained.Set(retained)
+	require.Nil(t, s.writeSnapshot())
+
+	s2 := testServer(cfg)
+	require.Nil(t, s2.readSnapshot())
+	assert.True(t, s2.recentTopics.Has("a"))
+	assert.True(t, s2.recentTopics.Has("b"))
+	assert.Equal(t, s.cache.Len(), s2.cache.Len())
+	assert.Equal(t, s.seq, s2.seq)
+	for i, id := range ids {
+		cur, ok := s2.ids.Get(id)
+		assert.True(t, ok)
+		want, _ := s.ids.Get(ids[i])
+		assert.Equal(t, want, cur)
+	}
+	var data []string
+	for _, val := range s2.cache.IterAfter("b", 0) {
+		var msg message
+		msg.FromJson(val)
+		data = append(data, msg.Data)
+	}
+	assert.Equal(t, []string{"a,b", "b"}, data)
+	if msgs := s2.retained.Match([]string{"a", "c"}, time.Now()); assert.Len(t, msgs, 1) {
+		assert.Equal(t, retained.ID, msgs[0].ID)
+		assert.Equal(t, retained.seq, msgs[0].seq)
+	}
+
+	// Truncated mid record
+	b, err := os.ReadFile(cfg.CACHE_SNAPSHOT)
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(cfg.CACHE_SNAPSHOT, b[:len(b)-10], 0o644))
+	s3 := testServer(cfg)
+	require.Nil(t, s3.readSnapshot())
+	assert.Equal(t, s.cache.Len()-1, s3.cache.Len())
+	assert.Len(t, s3.retained.Match([]string{"c"}, time.Now()), 1)
+	assert.True(t, s3.recentTopics.Has("a"))
+
+	// Unsupported version
+	require.Nil(t, os.WriteFile(cfg.CACHE_SNAPSHOT, []byte(`{"version":99}`+"\n"), 0o644))
+	assert.NotNil(t, testServer(cfg).readSnapshot())
+
+	// Missing
+	require.Nil(t, os.Remove(cfg.CACHE_SNAPSHOT))
+	assert.Nil(t, testServer(cfg).readSnapshot())
+}
+
+func TestSnapshotDisk(t *testing.T) {
+	cfg := Config{
+		PUBLISHER:      ConfigJWT{JWT_ALG: "HS256", JWT_KEY: pubKeyHS256},
+		SUBSCRIBER:     ConfigJWT{JWT_ALG: "HS256", JWT_KEY: subKeyHS256},
+		CACHE_DIR:      t.TempDir(),
+		CACHE_SNAPSHOT: filepath.Join(t.TempDir(), "snapshot.ndjson"),
+	}
+	s := testServer(cfg)
+	require.Nil(t, s.Start(t.Context()))
+	s.recentTopics.Add("a", time.Hour)
+	msg := newMessage("", []string{"a"}, "a")
+	require.True(t, s.sequence(msg, ""))
+	s.retained.Set(msg)
+	s.Stop()
+
+	// Cached messages are restored from disk, everything else from the snapshot
+	s2 := testServer(cfg)
+	require.Nil(t, s2.Start(t.Context()))
+	defer s2.Stop()
+	assert.True(t, s2.recentTopics.Has("a"))
+	assert.Len(t, s2.retained.Match([]string{"a"}, time.Now()), 1)
+	assert.Equal(t, 1, s2.cache.Len())
+}
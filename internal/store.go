@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+	"sync"
+
+	"github.com/logbn/mvfifo"
+)
+
+// Store stores messages by topic and cursor for Last-Event-ID replay.
+// Implementations evict the oldest messages first once their maximum size is exceeded.
+type Store interface {
+	// Add adds a message to the store by topic and cursor, evicting older messages as necessary.
+	Add(topic string, cur uint64, val []byte)
+
+	// IterAfter returns an iterator over the messages for a topic after a cursor.
+	IterAfter(topic string, cur uint64) iter.Seq2[uint64, []byte]
+
+	// First returns the oldest cursor and message in the store.
+	First() (cur uint64, val []byte)
+
+	// Resize changes the maximum size of the store, evicting older messages as necessary.
+	Resize(maxBytes int)
+
+	// Len returns the number of messages in the store.
+	Len() int
+
+	// Size returns the approximate size of the store in bytes.
+	Size() int
+
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+type storeItem struct {
+	cur uint64
+	val []byte
+}
+
+// storeMerge returns an iterator over the messages of several iterators ordered by cursor.
+// Each iterator is drained before the next so that no two hold a store lock at once.
+func storeMerge(iters ...iter.Seq2[uint64, []byte]) iter.Seq2[uint64, []byte] {
+	return func(yield func(uint64, []byte) bool) {
+		var items []storeItem
+		for _, it := range iters {
+			for cur, val := range it {
+				items = append(items, storeItem{cur, val})
+			}
+		}
+		slices.SortStableFunc(items, func(a, b storeItem) int {
+			return cmp.Compare(a.cur, b.cur)
+		})
+		for _, item := range items {
+			if !yield(item.cur, item.val) {
+				return
+			}
+		}
+	}
+}
+
+var storeMemoryPrune = 1024
+
+// storeMemory is an in-memory [Store] backed by a multi value FIFO cache.
+// Topics are tracked so that the store can be enumerated for snapshots. Topics whose messages
+// have all been evicted are pruned on add each time the number of tracked topics doubles.
+type storeMemory struct {
+	*mvfifo.Cache
+
+	topics map[string]bool
+	mutex  sync.Mutex
+	prune  int
+}
+
+func newStoreMemory(maxBytes int) *storeMemory {
+	return &storeMemory{
+		Cache:  mvfifo.NewCache(mvfifo.WithMaxSizeBytes(maxBytes)),
+		topics: make(map[string]bool),
+		prune:  storeMemoryPrune,
+	}
+}
+
+func (s *storeMemory) Add(topic string, cur uint64, val []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Cache.Add(topic, cur, val)
+	s.topics[topic] = true
+	if len(s.topics) >= s.prune {
+		for topic := range s.topics {
+			if s.empty(topic) {
+				delete(s.topics, topic)
+			}
+		}
+		s.prune = max(2*len(s.topics), storeMemoryPrune)
+	}
+}
+
+// empty indicates whether all messages for a topic have been evicted.
+func (s *storeMemory) empty(topic string) bool {
+	for range s.Cache.Iter(topic) {
+		return false
+	}
+	return true
+}
+
+// All returns an iterator over every message in the store in cursor order.
+// Each message is yielded once per topic.
+func (s *storeMemory) All() iter.Seq2[string, storeItem] {
+	return func(yield func(string, storeItem) bool) {
+		type topicItem struct {
+			topic string
+			storeItem
+		}
+		var items []topicItem
+		s.mutex.Lock()
+		for topic := range s.topics {
+			for cur, val := range s.Cache.Iter(topic) {
+				items = append(items, topicItem{topic, storeItem{cur, val}})
+			}
+		}
+		s.mutex.Unlock()
+		slices.SortStableFunc(items, func(a, b topicItem) int {
+			return cmp.Compare(a.cur, b.cur)
+		})
+		for _, item := range items {
+			if !yield(item.topic, item.storeItem) {
+				return
+			}
+		}
+	}
+}
+
+func (s *storeMemory) Close() error {
+	return nil
+}
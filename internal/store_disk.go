@@ -0,0 +1,408 @@
+package internal
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	storeDiskExt        = ".seg"
+	storeDiskHeaderSize = 8  // body length, crc32
+	storeDiskFixedSize  = 18 // cursor, expiration, topic length
+	storeDiskMinSegment = 1 << 20
+)
+
+// storeDisk is a [Store] persisted to a directory as a segmented append-only log.
+// Records are appended to the active segment, which is rolled once it exceeds the segment size.
+// Eviction deletes whole segments, oldest first. The index is rebuilt from the segments on open.
+// A truncated or corrupt record truncates its segment at that record.
+// Messages with a time to live are removed from the index once purged. Their bytes no longer count
+// toward the size of the store and segments holding only purged messages are deleted.
+type storeDisk struct {
+	dir      string
+	maxSize  int
+	segSize  int
+	mutex    sync.RWMutex
+	segments []*storeSegment
+	topics   map[string][]storeEntry
+	expiring []storeDiskRef
+	count    int
+	size     int
+}
+
+type storeSegment struct {
+	id      uint64
+	file    *os.File
+	size    int64
+	expired int64
+	count   int
+	first   storeEntry
+	topics  map[string]int
+}
+
+// storeDiskRef locates a record with a time to live in order of addition for purging.
+type storeDiskRef struct {
+	topic string
+	seg   *storeSegment
+	cur   uint64
+	exp   time.Time
+	size  int
+}
+
+type storeEntry struct {
+	seg *storeSegment
+	cur uint64
+	off int64
+	len int
+}
+
+func newStoreDisk(dir string, maxBytes int) (s *storeDisk, err error) {
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+	s = &storeDisk{
+		dir:     dir,
+		maxSize: maxBytes,
+		segSize: max(maxBytes/16, storeDiskMinSegment),
+		topics:  make(map[string][]storeEntry),
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*"+storeDiskExt))
+	if err != nil {
+		return
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		id, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), storeDiskExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		if err = s.load(id); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	if len(s.segments) == 0 {
+		if err = s.roll(); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	s.evict()
+	return
+}
+
+// load opens a segment and indexes its records, truncating any partial record at its tail.
+func (s *storeDisk) load(id uint64) (err error) {
+	f, err := os.OpenFile(s.segmentPath(id), os.O_RDWR, 0o644)
+	if err != nil {
+		return
+	}
+	seg := &storeSegment{id: id, file: f, topics: make(map[string]int)}
+	s.segments = append(s.segments, seg)
+	r := bufio.NewReader(f)
+	header := make([]byte, storeDiskHeaderSize)
+	var body []byte
+	for {
+		if _, err = io.ReadFull(r, header); err != nil {
+			break
+		}
+		n := binary.BigEndian.Uint32(header[0:4])
+		if n < storeDiskFixedSize {
+			err = fmt.Errorf("invalid record length %d", n)
+			break
+		}
+		body = slices.Grow(body[:0], int(n))[:n]
+		if _, err = io.ReadFull(r, body); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:8]) {
+			err = fmt.Errorf("invalid record checksum")
+			break
+		}
+		cur := binary.BigEndian.Uint64(body[0:8])
+		var exp time.Time
+		if nanos := int64(binary.BigEndian.Uint64(body[8:16])); nanos != 0 {
+			exp = time.Unix(0, nanos)
+		}
+		topicLen := int(binary.BigEndian.Uint16(body[16:18]))
+		if storeDiskFixedSize+topicLen > len(body) {
+			err = fmt.Errorf("invalid topic length %d", topicLen)
+			break
+		}
+		topic := string(body[storeDiskFixedSize : storeDiskFixedSize+topicLen])
+		s.index(seg, topic, cur, exp, seg.size, len(body))
+	}
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return f.Truncate(seg.size)
+}
+
+// index adds a record at offset off with body length n to the index.
+func (s *storeDisk) index(seg *storeSegment, topic string, cur uint64, exp time.Time, off int64, n int) {
+	size := storeDiskHeaderSize + n
+	topicLen := len(topic)
+	e := storeEntry{
+		seg: seg,
+		cur: cur,
+		off: off + storeDiskHeaderSize + storeDiskFixedSize + int64(topicLen),
+		len: n - storeDiskFixedSize - topicLen,
+	}
+	if seg.count == 0 {
+		seg.first = e
+	}
+	s.topics[topic] = append(s.topics[topic], e)
+	if !exp.IsZero() {
+		s.expiring = append(s.expiring, storeDiskRef{topic, seg, cur, exp, size})
+	}
+	seg.topics[topic]++
+	seg.count++
+	seg.size += int64(size)
+	s.count++
+	s.size += size
+}
+
+// Add appends a message to the active segment.
+func (s *storeDisk) Add(topic string, cur uint64, val []byte) {
+	s.add(topic, cur, val, time.Time{})
+}
+
+// AddTTL appends a message to the active segment that expires at a point in time.
+func (s *storeDisk) AddTTL(topic string, cur uint64, val []byte, exp time.Time) {
+	s.add(topic, cur, val, exp)
+}
+
+func (s *storeDisk) add(topic string, cur uint64, val []byte, exp time.Time) {
+	if len(topic) > 1<<16-1 {
+		log.Printf("Cache record %d dropped: topic length %d exceeds %d", cur, len(topic), 1<<16-1)
+		return
+	}
+	n := storeDiskFixedSize + len(topic) + len(val)
+	buf := make([]byte, storeDiskHeaderSize+n)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(n))
+	binary.BigEndian.PutUint64(buf[8:16], cur)
+	if !exp.IsZero() {
+		binary.BigEndian.PutUint64(buf[16:24], uint64(exp.UnixNano()))
+	}
+	binary.BigEndian.PutUint16(buf[24:26], uint16(len(topic)))
+	copy(buf[26:], topic)
+	copy(buf[26+len(topic):], val)
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(buf[storeDiskHeaderSize:]))
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.segments) == 0 {
+		return
+	}
+	seg := s.segments[len(s.segments)-1]
+	if _, err := seg.file.WriteAt(buf, seg.size); err != nil {
+		log.Printf("Cache record %d dropped: %v", cur, err)
+		return
+	}
+	s.index(seg, topic, cur, exp, seg.size, n)
+	if seg.size >= int64(s.segSize) {
+		if err := s.roll(); err != nil {
+			log.Printf("Cache segment not rolled: %v", err)
+		}
+	}
+	s.evict()
+}
+
+// IterAfter returns an iterator over the messages for a topic after a cursor.
+func (s *storeDisk) IterAfter(topic string, cur uint64) iter.Seq2[uint64, []byte] {
+	return func(yield func(cur uint64, val []byte) bool) {
+		s.mutex.RLock()
+		defer s.mutex.RUnlock()
+		entries := s.topics[topic]
+		i := sort.Search(len(entries), func(i int) bool {
+			return entries[i].cur > cur
+		})
+		for _, e := range entries[i:] {
+			val, err := s.read(e)
+			if err != nil || !yield(e.cur, val) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator over every message in the store in cursor order.
+// Each message is yielded once per topic.
+func (s *storeDisk) All() iter.Seq2[string, storeItem] {
+	return func(yield func(string, storeItem) bool) {
+		type topicEntry struct {
+			topic string
+			storeEntry
+		}
+		var entries []topicEntry
+		s.mutex.RLock()
+		defer s.mutex.RUnlock()
+		for topic, topicEntries := range s.topics {
+			for _, e := range topicEntries {
+				entries = append(entries, topicEntry{topic, e})
+			}
+		}
+		slices.SortStableFunc(entries, func(a, b topicEntry) int {
+			return cmp.Compare(a.cur, b.cur)
+		})
+		for _, e := range entries {
+			val, err := s.read(e.storeEntry)
+			if err != nil || !yield(e.topic, storeItem{e.cur, val}) {
+				return
+			}
+		}
+	}
+}
+
+// First returns the oldest cursor and message in the store.
+func (s *storeDisk) First() (cur uint64, val []byte) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, seg := range s.segments {
+		if seg.count > 0 {
+			val, _ = s.read(seg.first)
+			return seg.first.cur, val
+		}
+	}
+	return
+}
+
+// Purge removes expired messages from the index and deletes segments left without messages.
+func (s *storeDisk) Purge(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.expiring = slices.DeleteFunc(s.expiring, func(ref storeDiskRef) bool {
+		if ref.exp.After(now) {
+			return false
+		}
+		entries := s.topics[ref.topic]
+		i := sort.Search(len(entries), func(i int) bool {
+			return entries[i].cur >= ref.cur
+		})
+		if i == len(entries) || entries[i].cur != ref.cur || entries[i].seg != ref.seg {
+			// Evicted
+			return true
+		}
+		if len(entries) == 1 {
+			delete(s.topics, ref.topic)
+		} else {
+			s.topics[ref.topic] = slices.Delete(entries, i, i+1)
+		}
+		if ref.seg.topics[ref.topic]--; ref.seg.topics[ref.topic] == 0 {
+			delete(ref.seg.topics, ref.topic)
+		}
+		ref.seg.count--
+		ref.seg.expired += int64(ref.size)
+		s.count--
+		s.size -= ref.size
+		return true
+	})
+	if len(s.segments) == 0 {
+		return
+	}
+	active := s.segments[len(s.segments)-1]
+	s.segments = slices.DeleteFunc(s.segments, func(seg *storeSegment) bool {
+		if seg.count > 0 || seg == active {
+			return false
+		}
+		seg.file.Close()
+		os.Remove(seg.file.Name())
+		return true
+	})
+}
+
+// Resize changes the maximum size of the store.
+func (s *storeDisk) Resize(maxBytes int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.maxSize = maxBytes
+	s.evict()
+}
+
+// Len returns the number of messages in the store.
+func (s *storeDisk) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.count
+}
+
+// Size returns the size of the store in bytes excluding purged messages.
+func (s *storeDisk) Size() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.size
+}
+
+// Close syncs and closes all segments.
+func (s *storeDisk) Close() (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, seg := range s.segments {
+		err = errors.Join(err, seg.file.Sync(), seg.file.Close())
+	}
+	s.segments = nil
+	return
+}
+
+func (s *storeDisk) read(e storeEntry) (val []byte, err error) {
+	val = make([]byte, e.len)
+	_, err = e.seg.file.ReadAt(val, e.off)
+	return
+}
+
+// roll creates a new active segment.
+func (s *storeDisk) roll() (err error) {
+	var id uint64
+	if len(s.segments) > 0 {
+		id = s.segments[len(s.segments)-1].id + 1
+	}
+	f, err := os.OpenFile(s.segmentPath(id), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return
+	}
+	s.segments = append(s.segments, &storeSegment{id: id, file: f, topics: make(map[string]int)})
+	return
+}
+
+// evict deletes the oldest segments until the store is within its maximum size.
+// The active segment is rolled before it is evicted.
+func (s *storeDisk) evict() {
+	if s.size > s.maxSize && len(s.segments) == 1 {
+		if err := s.roll(); err != nil {
+			log.Printf("Cache segment not rolled: %v", err)
+		}
+	}
+	for s.size > s.maxSize && len(s.segments) > 1 {
+		seg := s.segments[0]
+		for topic, n := range seg.topics {
+			if entries := s.topics[topic][n:]; len(entries) > 0 {
+				s.topics[topic] = entries
+			} else {
+				delete(s.topics, topic)
+			}
+		}
+		s.count -= seg.count
+		s.size -= int(seg.size - seg.expired)
+		seg.file.Close()
+		os.Remove(seg.file.Name())
+		s.segments = s.segments[1:]
+	}
+}
+
+func (s *storeDisk) segmentPath(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", id, storeDiskExt))
+}
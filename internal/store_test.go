@@ -0,0 +1,237 @@
+package internal
+
+import (
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T, maxBytes int) Store{
+		"memory": func(t *testing.T, maxBytes int) Store {
+			return newStoreMemory(maxBytes)
+		},
+		"disk": func(t *testing.T, maxBytes int) Store {
+			s, err := newStoreDisk(t.TempDir(), maxBytes)
+			require.Nil(t, err)
+			return s
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := open(t, 1<<20)
+			defer s.Close()
+			for i := range 10 {
+				s.Add(fmt.Sprintf("topic-%d", i%2), uint64(i+1), fmt.Appendf(nil, "value-%d", i+1))
+			}
+			assert.Equal(t, 10, s.Len())
+			assert.Greater(t, s.Size(), 0)
+			cur, val := s.First()
+			assert.EqualValues(t, 1, cur)
+			assert.Equal(t, "value-1", string(val))
+			var curs []uint64
+			for cur, val := range s.IterAfter("topic-1", 4) {
+				curs = append(curs, cur)
+				assert.Equal(t, fmt.Sprintf("value-%d", cur), string(val))
+			}
+			assert.Equal(t, []uint64{6, 8, 10}, curs)
+			for range s.IterAfter("topic-2", 0) {
+				t.Fatal("Unexpected value for missing topic")
+			}
+			s.Resize(0)
+			assert.Less(t, s.Len(), 10)
+		})
+	}
+}
+
+func TestStoreMemoryPrune(t *testing.T) {
+	s := newStoreMemory(1 << 10)
+	for i := range 100 * storeMemoryPrune {
+		s.Add(fmt.Sprintf("topic-%d", i), uint64(i+1), []byte("value"))
+	}
+	assert.Less(t, len(s.topics), 2*storeMemoryPrune)
+	var n int
+	for range s.All() {
+		n++
+	}
+	assert.Equal(t, s.Len(), n)
+}
+
+func TestStoreDisk(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newStoreDisk(dir, 4<<20)
+	require.Nil(t, err)
+	val := make([]byte, 64<<10)
+	for i := range 100 {
+		s.Add("test", uint64(i+1), val)
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+storeDiskExt))
+	assert.Greater(t, len(segments), 1)
+	assert.LessOrEqual(t, s.Size(), 4<<20+storeDiskMinSegment+len(val))
+	first, _ := s.First()
+	assert.Greater(t, first, uint64(1))
+	n := s.Len()
+	require.Nil(t, s.Close())
+	t.Run("reopen", func(t *testing.T) {
+		s, err = newStoreDisk(dir, 4<<20)
+		require.Nil(t, err)
+		assert.Equal(t, n, s.Len())
+		cur, _ := s.First()
+		assert.Equal(t, first, cur)
+		var all []uint64
+		for topic, item := range s.All() {
+			assert.Equal(t, "test", topic)
+			all = append(all, item.cur)
+		}
+		assert.Len(t, all, n)
+		assert.True(t, slices.IsSorted(all))
+		assert.Equal(t, first, all[0])
+		s.Add("test", 101, []byte("next"))
+		var last uint64
+		for cur := range s.IterAfter("test", 0) {
+			last = cur
+		}
+		assert.EqualValues(t, 101, last)
+		require.Nil(t, s.Close())
+	})
+	t.Run("truncated", func(t *testing.T) {
+		segments, _ := filepath.Glob(filepath.Join(dir, "*"+storeDiskExt))
+		active := segments[len(segments)-1]
+		info, err := os.Stat(active)
+		require.Nil(t, err)
+		require.Nil(t, os.Truncate(active, info.Size()-2))
+		s, err = newStoreDisk(dir, 4<<20)
+		require.Nil(t, err)
+		defer s.Close()
+		assert.Equal(t, n, s.Len())
+		for cur := range s.IterAfter("test", 0) {
+			assert.Less(t, cur, uint64(101))
+		}
+		s.Add("test", 102, []byte("next"))
+		var last uint64
+		for cur, val := range s.IterAfter("test", 100) {
+			last = cur
+			assert.Equal(t, "next", string(val))
+		}
+		assert.EqualValues(t, 102, last)
+	})
+}
+
+func TestStoreDiskTTL(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newStoreDisk(dir, 4<<20)
+	require.Nil(t, err)
+	now := time.Now()
+	val := make([]byte, 64<<10)
+	for i := range 20 {
+		s.AddTTL("typing", uint64(i+1), val, now.Add(time.Second))
+	}
+	s.Add("test", 21, []byte("next"))
+	s.AddTTL("typing", 22, []byte("later"), now.Add(time.Hour))
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+storeDiskExt))
+	assert.Greater(t, len(segments), 1)
+	s.Purge(now)
+	assert.Equal(t, 22, s.Len())
+	size := s.Size()
+	s.Purge(now.Add(time.Second))
+	assert.Equal(t, 2, s.Len())
+	assert.Less(t, s.Size(), size-20*len(val))
+	assert.Equal(t, []string{"later"}, storeVals(s.IterAfter("typing", 0)))
+	assert.Equal(t, []string{"next"}, storeVals(s.IterAfter("test", 0)))
+	// Segments holding only purged messages are deleted
+	remaining, _ := filepath.Glob(filepath.Join(dir, "*"+storeDiskExt))
+	assert.Less(t, len(remaining), len(segments))
+	require.Nil(t, s.Close())
+
+	s, err = newStoreDisk(dir, 4<<20)
+	require.Nil(t, err)
+	defer s.Close()
+	s.Purge(now.Add(time.Second))
+	assert.Equal(t, 2, s.Len())
+	s.Purge(now.Add(time.Hour))
+	assert.Equal(t, 1, s.Len())
+	assert.Nil(t, storeVals(s.IterAfter("typing", 0)))
+}
+
+func storeVals(it iter.Seq2[uint64, []byte]) (vals []string) {
+	for _, val := range it {
+		vals = append(vals, string(val))
+	}
+	return
+}
+
+func TestStoreMerge(t *testing.T) {
+	s := newStoreMemory(1 << 20)
+	for i, topic := range []string{"a", "b", "a", "c", "b"} {
+		s.Add(topic, uint64(i+1), fmt.Appendf(nil, "%s-%d", topic, i+1))
+	}
+	s.Add("c", 5, []byte("c-5"))
+	var vals []string
+	for _, val := range storeMerge(s.IterAfter("c", 0), s.IterAfter("a", 1), s.IterAfter("b", 0)) {
+		vals = append(vals, string(val))
+	}
+	assert.Equal(t, []string{"b-2", "a-3", "c-4", "c-5", "b-5"}, vals)
+}
+
+func TestStoreTTL(t *testing.T) {
+	clk := clock.NewMock()
+	s := newStoreTTL(newStoreMemory(1<<20), 1<<20, clk)
+	s.Add("a", 1, []byte("1"))
+	s.AddTTL("a", 2, []byte("2"), clk.Now().Add(time.Second))
+	s.AddTTL("a", 3, []byte("3"), clk.Now().Add(time.Minute))
+	s.Add("a", 4, []byte("4"))
+	iterVals := func() (vals []string) {
+		for _, val := range s.IterAfter("a", 0) {
+			vals = append(vals, string(val))
+		}
+		return
+	}
+	assert.Equal(t, []string{"1", "2", "3", "4"}, iterVals())
+	assert.Equal(t, 4, s.Len())
+	size := s.Size()
+	clk.Add(time.Second)
+	assert.Equal(t, []string{"1", "3", "4"}, iterVals())
+	assert.Equal(t, 4, s.Len())
+	s.Purge()
+	assert.Equal(t, 3, s.Len())
+	assert.Equal(t, size-1, s.Size())
+	clk.Add(time.Minute)
+	s.Purge()
+	assert.Equal(t, []string{"1", "4"}, iterVals())
+	assert.Equal(t, 2, s.Len())
+}
+
+func TestStoreTTLSize(t *testing.T) {
+	clk := clock.NewMock()
+	s := newStoreTTL(newStoreMemory(1<<20), 1<<20, clk)
+	val := make([]byte, 64<<10)
+	for i := range 8 {
+		s.Add("a", uint64(i+1), val)
+	}
+	for i := range 64 {
+		s.AddTTL("b", uint64(i+9), val, clk.Now().Add(time.Duration(i+1)*time.Second))
+	}
+	assert.LessOrEqual(t, s.Size(), 1<<20)
+	var curs []uint64
+	for cur := range s.IterAfter("b", 0) {
+		curs = append(curs, cur)
+	}
+	assert.EqualValues(t, 72, curs[len(curs)-1])
+	assert.Greater(t, curs[0], uint64(9))
+	for range s.IterAfter("a", 0) {
+		t.Fatal("Unexpected value outside the remaining budget")
+	}
+	clk.Add(time.Hour)
+	s.Purge()
+	assert.Equal(t, 0, s.Len())
+	s.Add("a", 73, val)
+	assert.Equal(t, 1, s.Len())
+}
@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+// storeTTL is a [Store] that holds messages with a time to live apart from an underlying store
+// so that they can be skipped on replay and purged as soon as they expire. Both share a single
+// size budget. The underlying store is resized to the remainder of the budget as messages with a
+// time to live are added and purged, and the oldest of those are evicted once they alone exceed it.
+// Messages with a time to live are passed through to an underlying [storeExpirer].
+type storeTTL struct {
+	Store
+
+	clock   clock.Clock
+	count   int
+	maxSize int
+	mutex   sync.RWMutex
+	order   []storeTTLRef
+	size    int
+	topics  map[string][]storeTTLItem
+}
+
+type storeTTLItem struct {
+	cur uint64
+	val []byte
+	exp time.Time
+}
+
+// storeTTLRef locates a message with a time to live in order of addition for eviction.
+type storeTTLRef struct {
+	topic string
+	cur   uint64
+	exp   time.Time
+}
+
+// storeExpirer is a [Store] that holds messages with a time to live itself, i.e. to persist them.
+type storeExpirer interface {
+	Store
+
+	// AddTTL adds a message to the store by topic and cursor that expires at a point in time.
+	AddTTL(topic string, cur uint64, val []byte, exp time.Time)
+
+	// Purge removes messages that expired before a point in time.
+	Purge(now time.Time)
+}
+
+func newStoreTTL(store Store, maxBytes int, clk clock.Clock) *storeTTL {
+	return &storeTTL{
+		Store:   store,
+		clock:   clk,
+		maxSize: maxBytes,
+		topics:  make(map[string][]storeTTLItem),
+	}
+}
+
+// AddTTL adds a message to the store by topic and cursor that expires at a point in time.
+func (s *storeTTL) AddTTL(topic string, cur uint64, val []byte, exp time.Time) {
+	if store, ok := s.Store.(storeExpirer); ok {
+		store.AddTTL(topic, cur, val, exp)
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.topics[topic] = append(s.topics[topic], storeTTLItem{cur, val, exp})
+	s.order = append(s.order, storeTTLRef{topic, cur, exp})
+	s.count++
+	s.size += len(val)
+	s.evict()
+}
+
+// Resize changes the maximum size of the store, evicting older messages as necessary.
+func (s *storeTTL) Resize(maxBytes int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.maxSize = maxBytes
+	s.evict()
+}
+
+// evict removes the oldest messages with a time to live until they fit the budget and resizes the
+// underlying store to the remainder.
+func (s *storeTTL) evict() {
+	for s.size > s.maxSize && len(s.order) > 0 {
+		ref := s.order[0]
+		s.order = s.order[1:]
+		items := s.topics[ref.topic]
+		if len(items) == 0 || items[0].cur != ref.cur {
+			continue
+		}
+		s.count--
+		s.size -= len(items[0].val)
+		if len(items) == 1 {
+			delete(s.topics, ref.topic)
+		} else {
+			s.topics[ref.topic] = items[1:]
+		}
+	}
+	s.Store.Resize(s.maxSize - s.size)
+}
+
+// IterAfter returns an iterator over the unexpired messages for a topic after a cursor.
+func (s *storeTTL) IterAfter(topic string, cur uint64) iter.Seq2[uint64, []byte] {
+	return storeMerge(s.Store.IterAfter(topic, cur), s.iterAfter(topic, cur))
+}
+
+func (s *storeTTL) iterAfter(topic string, cur uint64) iter.Seq2[uint64, []byte] {
+	return func(yield func(uint64, []byte) bool) {
+		s.mutex.RLock()
+		defer s.mutex.RUnlock()
+		now := s.clock.Now()
+		items := s.topics[topic]
+		i := sort.Search(len(items), func(i int) bool {
+			return items[i].cur > cur
+		})
+		for _, item := range items[i:] {
+			if item.exp.After(now) && !yield(item.cur, item.val) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator over every unexpired message with a time to live by topic.
+func (s *storeTTL) All() iter.Seq2[string, storeTTLItem] {
+	return func(yield func(string, storeTTLItem) bool) {
+		s.mutex.RLock()
+		defer s.mutex.RUnlock()
+		now := s.clock.Now()
+		for topic, items := range s.topics {
+			for _, item := range items {
+				if item.exp.After(now) && !yield(topic, item) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Run purges expired messages every second until the context is done.
+func (s *storeTTL) Run(ctx context.Context) {
+	t := s.clock.Ticker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.Purge()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Purge removes expired messages.
+func (s *storeTTL) Purge() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.clock.Now()
+	if store, ok := s.Store.(storeExpirer); ok {
+		store.Purge(now)
+	}
+	for topic, items := range s.topics {
+		var n int
+		for _, item := range items {
+			if item.exp.After(now) {
+				items[n] = item
+				n++
+				continue
+			}
+			s.count--
+			s.size -= len(item.val)
+		}
+		if n == 0 {
+			delete(s.topics, topic)
+		} else {
+			clear(items[n:])
+			s.topics[topic] = items[:n]
+		}
+	}
+	s.order = slices.DeleteFunc(s.order, func(ref storeTTLRef) bool {
+		return !ref.exp.After(now)
+	})
+	s.Store.Resize(s.maxSize - s.size)
+}
+
+// Len returns the number of messages in the store including unpurged messages with a time to live.
+func (s *storeTTL) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.Store.Len() + s.count
+}
+
+// Size returns the approximate size of the store in bytes including unpurged messages with a time to live.
+func (s *storeTTL) Size() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.Store.Size() + s.size
+}
@@ -1,12 +1,15 @@
 package internal
 
+const subscriptionContext = "github.com/pantopic/mercure-lite"
+
 type subscription struct {
-	ID         string         `json:"id"`
-	Type       string         `json:"type"`
-	Topic      string         `json:"topic"`
-	Subscriber string         `json:"subscriber"`
-	Active     bool           `json:"active"`
-	Payload    map[string]any `json:"payload"`
+	Context    string `json:"@context,omitempty"`
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Topic      string `json:"topic"`
+	Subscriber string `json:"subscriber"`
+	Active     bool   `json:"active"`
+	Payload    any    `json:"payload"`
 }
 
 type subscriptionList struct {
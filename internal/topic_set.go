@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"iter"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+var topicSetPrune = time.Minute
+
+// topicSet is an expiring set of topics whose members can be enumerated for snapshots.
+// URI template selectors in the set are indexed so that topics can be matched against them.
+// Expired topics are pruned on add at most once per prune interval.
+type topicSet struct {
+	clock     clock.Clock
+	expires   map[string]time.Time
+	mutex     sync.RWMutex
+	pruned    time.Time
+	selectors *selectorIndex[string]
+}
+
+func newTopicSet(clk clock.Clock) *topicSet {
+	return &topicSet{
+		clock:     clk,
+		expires:   make(map[string]time.Time),
+		pruned:    clk.Now(),
+		selectors: newSelectorIndex[string](),
+	}
+}
+
+// Add adds a topic to the set with a given time to live.
+func (s *topicSet) Add(topic string, ttl time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.clock.Now()
+	if now.Sub(s.pruned) >= topicSetPrune {
+		s.prune(now)
+	}
+	s.expires[topic] = now.Add(ttl)
+	if topic != topicAll && isSelector(topic) {
+		s.selectors.Add(topic, topic)
+	}
+}
+
+// Has indicates whether the set contains an unexpired topic.
+func (s *topicSet) Has(topic string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	exp, ok := s.expires[topic]
+	return ok && exp.After(s.clock.Now())
+}
+
+// Len returns the number of topics in the set including unpruned expired topics.
+func (s *topicSet) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.expires)
+}
+
+// Selectors returns the unexpired URI template selectors in the set matching any of the topics.
+func (s *topicSet) Selectors(topics []string) (res []string) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	now := s.clock.Now()
+	for _, topic := range topics {
+		s.selectors.Match(topic, func(sel string) {
+			if s.expires[sel].After(now) && !slices.Contains(res, sel) {
+				res = append(res, sel)
+			}
+		})
+	}
+	return
+}
+
+// All returns an iterator over the unexpired topics in the set and their expiration.
+func (s *topicSet) All() iter.Seq2[string, time.Time] {
+	return func(yield func(string, time.Time) bool) {
+		s.mutex.RLock()
+		defer s.mutex.RUnlock()
+		now := s.clock.Now()
+		for topic, exp := range s.expires {
+			if exp.After(now) && !yield(topic, exp) {
+				return
+			}
+		}
+	}
+}
+
+// prune removes expired topics.
+func (s *topicSet) prune(now time.Time) {
+	for topic, exp := range s.expires {
+		if !exp.After(now) {
+			delete(s.expires, topic)
+			s.selectors.Remove(topic, topic)
+		}
+	}
+	s.pruned = now
+}
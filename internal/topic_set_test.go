@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicSet(t *testing.T) {
+	clk := clock.NewMock()
+	s := newTopicSet(clk)
+	s.Add("a", time.Second)
+	s.Add("https://example.com/books/{id}", time.Hour)
+	assert.True(t, s.Has("a"))
+	assert.Equal(t, []string{"https://example.com/books/{id}"}, s.Selectors([]string{"https://example.com/books/1"}))
+	assert.Nil(t, s.Selectors([]string{"https://example.com/authors/1"}))
+	assert.Len(t, s.Selectors([]string{"https://example.com/books/1", "https://example.com/books/2"}), 1)
+	clk.Add(time.Second)
+	assert.False(t, s.Has("a"))
+	for topic := range s.All() {
+		assert.NotEqual(t, "a", topic)
+	}
+	assert.Equal(t, 2, s.Len())
+	clk.Add(topicSetPrune)
+	s.Add("b", time.Hour)
+	assert.Equal(t, 2, s.Len())
+	clk.Add(time.Hour)
+	assert.Nil(t, s.Selectors([]string{"https://example.com/books/1"}))
+	clk.Add(topicSetPrune)
+	s.Add("c", time.Hour)
+	assert.Equal(t, 1, s.Len())
+	assert.Equal(t, 0, s.selectors.Len())
+}
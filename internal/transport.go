@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync"
+)
+
+// Transport distributes messages between the nodes of a cluster.
+type Transport interface {
+	// Publish sends a message published to this node to the other nodes.
+	Publish(msg *message) error
+
+	// Run passes messages published to other nodes to fn until the context is done.
+	Run(ctx context.Context, fn func(*message))
+
+	// Close releases any resources held by the transport.
+	Close() error
+}
+
+// History is implemented by transports that retain messages for replay by any node.
+type History interface {
+	// Replay returns an iterator over the retained messages to any of the topics published after
+	// the message with the given ID in publish order. All retained messages to the topics are
+	// replayed after "earliest". Returns false if the ID is not retained.
+	Replay(topics []string, id string) (iter.Seq[*message], bool)
+}
+
+// newTransport returns the transport specified by the config or nil for a single node.
+func newTransport(cfg Config) (Transport, error) {
+	switch cfg.TRANSPORT {
+	case "":
+		return nil, nil
+	case "local":
+		return newTransportLocal(transportLocalBus), nil
+	case "peer":
+		return newTransportPeer(cfg)
+	case "redis":
+		return newTransportRedis(cfg)
+	case "nats":
+		return newTransportNats(cfg)
+	case "postgres":
+		return newTransportPostgres(cfg)
+	}
+	return nil, fmt.Errorf("Unknown transport %q", cfg.TRANSPORT)
+}
+
+// transportBus connects the local transports of nodes running in the same process.
+type transportBus struct {
+	mutex sync.RWMutex
+	nodes map[*transportLocal]bool
+}
+
+var transportLocalBus = &transportBus{nodes: make(map[*transportLocal]bool)}
+
+// transportLocal is an in-process [Transport] for nodes sharing a bus.
+type transportLocal struct {
+	bus  *transportBus
+	recv chan *message
+}
+
+func newTransportLocal(bus *transportBus) *transportLocal {
+	t := &transportLocal{
+		bus:  bus,
+		recv: make(chan *message, 1024),
+	}
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.nodes[t] = true
+	return t
+}
+
+// Publish sends a copy of the message to every other node on the bus.
+// Messages are dropped for nodes that are not keeping up.
+func (t *transportLocal) Publish(msg *message) (err error) {
+	t.bus.mutex.RLock()
+	defer t.bus.mutex.RUnlock()
+	for node := range t.bus.nodes {
+		if node == t {
+			continue
+		}
+		m := *msg
+		m.seq = 0
+		select {
+		case node.recv <- &m:
+		default:
+			err = fmt.Errorf("Transport buffer full, message %s dropped", msg.ID)
+		}
+	}
+	return
+}
+
+func (t *transportLocal) Run(ctx context.Context, fn func(*message)) {
+	for {
+		select {
+		case msg := <-t.recv:
+			fn(msg)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *transportLocal) Close() error {
+	t.bus.mutex.Lock()
+	defer t.bus.mutex.Unlock()
+	delete(t.bus.nodes, t)
+	return nil
+}
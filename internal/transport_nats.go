@@ -0,0 +1,237 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"iter"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+var (
+	transportNatsBatch   = uint64(1000)
+	transportNatsStream  = "MERCURE_LITE"
+	transportNatsSubject = "mercure"
+	transportNatsTimeout = 5 * time.Second
+)
+
+// transportNatsNode is the header identifying the node to which a message was published.
+const transportNatsNode = "Mercure-Node"
+
+// transportNats is a [Transport] publishing each message to a NATS JetStream subject derived
+// from its first topic. The stream retains messages up to the history size and serves replay for
+// every node. Stream sequences are indexed by message ID as messages are consumed so that replay
+// can start from the position of any message retained by the stream.
+type transportNats struct {
+	conn    *nats.Conn
+	history uint64
+	ids     *idIndex
+	js      jetstream.JetStream
+	node    string
+	stream  jetstream.Stream
+}
+
+// transportNatsSubjectFor returns the subject for a topic. Topics are base64 encoded since they
+// may contain characters which are not valid in subjects.
+// i.e. https://example.com/orders/1 is published to mercure.aHR0cHM6Ly9leGFtcGxlLmNvbS9vcmRlcnMvMQ
+func transportNatsSubjectFor(topic string) string {
+	return transportNatsSubject + "." + base64.RawURLEncoding.EncodeToString([]byte(topic))
+}
+
+func newTransportNats(cfg Config) (t *transportNats, err error) {
+	t = &transportNats{
+		history: uint64(max(cfg.TRANSPORT_HISTORY, 1)),
+		ids:     newIDIndex(),
+		node:    uuidv7(),
+	}
+	if t.conn, err = nats.Connect(cfg.TRANSPORT_URL); err != nil {
+		return nil, err
+	}
+	if t.js, err = jetstream.New(t.conn); err != nil {
+		t.conn.Close()
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), transportNatsTimeout)
+	defer cancel()
+	t.stream, err = t.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     transportNatsStream,
+		Subjects: []string{transportNatsSubject + ".>"},
+		MaxMsgs:  int64(t.history),
+	})
+	if err != nil {
+		t.conn.Close()
+		return nil, err
+	}
+	return
+}
+
+// Publish publishes a message to the stream.
+func (t *transportNats) Publish(msg *message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), transportNatsTimeout)
+	defer cancel()
+	m := nats.NewMsg(transportNatsSubjectFor(msg.Topics[0]))
+	m.Data = msg.ToJson()
+	m.Header.Set(jetstream.MsgIDHeader, msg.ID)
+	m.Header.Set(transportNatsNode, t.node)
+	ack, err := t.js.PublishMsg(ctx, m)
+	if err != nil {
+		return err
+	}
+	t.index(msg.ID, ack.Sequence)
+	return nil
+}
+
+// Run consumes the stream from the beginning, indexing every message and passing messages
+// published to other nodes after the transport started to fn. The consumer is created again from
+// the last consumed message until the context is done.
+func (t *transportNats) Run(ctx context.Context, fn func(*message)) {
+	var live, next uint64
+	for {
+		err := t.consume(ctx, &live, &next, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Transport consumer closed: %v", err)
+		select {
+		case <-time.After(transportRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// consume consumes the stream from the next sequence, which is zero until the first consumer has
+// started. Messages after the live sequence, the last in the stream when the first consumer
+// started, are passed to fn.
+func (t *transportNats) consume(ctx context.Context, live, next *uint64, fn func(*message)) error {
+	if *next == 0 {
+		info, err := t.stream.Info(ctx)
+		if err != nil {
+			return err
+		}
+		*live = info.State.LastSeq
+		*next = max(info.State.FirstSeq, 1)
+	}
+	cons, err := t.stream.OrderedConsumer(ctx, jetstream.OrderedConsumerConfig{
+		DeliverPolicy: jetstream.DeliverByStartSequencePolicy,
+		OptStartSeq:   *next,
+	})
+	if err != nil {
+		return err
+	}
+	msgs, err := cons.Messages()
+	if err != nil {
+		return err
+	}
+	defer msgs.Stop()
+	stop := context.AfterFunc(ctx, msgs.Stop)
+	defer stop()
+	for {
+		m, err := msgs.Next()
+		if err != nil {
+			return err
+		}
+		msg, seq := t.parse(m)
+		if seq > 0 {
+			*next = seq + 1
+		}
+		if msg == nil {
+			continue
+		}
+		t.index(msg.ID, seq)
+		if seq > *live && m.Headers().Get(transportNatsNode) != t.node {
+			fn(msg)
+		}
+	}
+}
+
+// Replay consumes the stream from the position after the message with the given ID.
+// The consumer is deleted once the retained messages have been read.
+func (t *transportNats) Replay(topics []string, id string) (iter.Seq[*message], bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), transportNatsTimeout)
+	defer cancel()
+	info, err := t.stream.Info(ctx)
+	if err != nil {
+		return nil, false
+	}
+	start := info.State.FirstSeq
+	if id != lastEventEarliest {
+		seq, ok := t.ids.Get(id)
+		if !ok || seq < info.State.FirstSeq {
+			return nil, false
+		}
+		start = seq + 1
+	}
+	var msgs []*message
+	if start > info.State.LastSeq {
+		return slices.Values(msgs), true
+	}
+	cons, err := t.stream.CreateConsumer(ctx, jetstream.ConsumerConfig{
+		DeliverPolicy:     jetstream.DeliverByStartSequencePolicy,
+		OptStartSeq:       start,
+		AckPolicy:         jetstream.AckNonePolicy,
+		InactiveThreshold: 2 * transportNatsTimeout,
+	})
+	if err != nil {
+		return nil, false
+	}
+	defer t.delete(cons)
+	matcher := newTopicMatcher(topics)
+	for seq := start - 1; seq < info.State.LastSeq; {
+		n := int(min(info.State.LastSeq-seq, transportNatsBatch))
+		batch, err := cons.Fetch(n, jetstream.FetchMaxWait(transportNatsTimeout))
+		if err != nil {
+			return nil, false
+		}
+		var fetched int
+		for m := range batch.Messages() {
+			fetched++
+			var msg *message
+			if msg, seq = t.parse(m); msg != nil && matcher.Match(msg.Topics...) {
+				msgs = append(msgs, msg)
+			}
+		}
+		if batch.Error() != nil || fetched == 0 {
+			break
+		}
+	}
+	return slices.Values(msgs), true
+}
+
+// delete deletes a consumer from the stream.
+func (t *transportNats) delete(cons jetstream.Consumer) {
+	ctx, cancel := context.WithTimeout(context.Background(), transportNatsTimeout)
+	defer cancel()
+	if err := t.stream.DeleteConsumer(ctx, cons.CachedInfo().Name); err != nil {
+		log.Printf("Transport consumer not deleted: %v", err)
+	}
+}
+
+func (t *transportNats) parse(m jetstream.Msg) (msg *message, seq uint64) {
+	meta, err := m.Metadata()
+	if err != nil {
+		return
+	}
+	msg = &message{}
+	msg.FromJson(m.Data())
+	if len(msg.ID) == 0 {
+		return nil, meta.Sequence.Stream
+	}
+	return msg, meta.Sequence.Stream
+}
+
+func (t *transportNats) index(id string, seq uint64) {
+	t.ids.Add(id, seq, seq-min(seq, t.history))
+}
+
+func (t *transportNats) Close() error {
+	if err := t.conn.Drain(); err != nil {
+		return fmt.Errorf("Transport drain failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package internal
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tmaxmax/go-sse"
+)
+
+var (
+	transportPath              = "/.well-known/mercure/transport"
+	transportRetryDelay        = time.Second
+	transportDiscoveryInterval = 30 * time.Second
+	transportLookupSRV         = net.LookupSRV
+)
+
+// transportPeer is a [Transport] connecting a mesh of peers over HTTP. Peers are listed statically
+// and discovered from a DNS SRV record. Each node serves the messages published to it as an event
+// stream to which every peer subscribes. Each event holds the JSON encoding of a message as its
+// data. Streams are authenticated by a key shared by all nodes. Messages published while a stream
+// is reconnecting are not delivered to that peer.
+//
+// The IDs of recent messages are remembered so that a message is passed on at most once, even if
+// a node discovers itself or a peer under more than one address.
+type transportPeer struct {
+	client  *http.Client
+	history uint64
+	key     string
+	mutex   sync.RWMutex
+	peers   []string
+	seen    *idIndex
+	seq     atomic.Uint64
+	srv     string
+	streams map[chan *message]bool
+}
+
+func newTransportPeer(cfg Config) (*transportPeer, error) {
+	if len(cfg.TRANSPORT_KEY) == 0 {
+		return nil, fmt.Errorf("Peer transport requires a transport key")
+	}
+	return &transportPeer{
+		client:  &http.Client{},
+		history: uint64(max(cfg.TRANSPORT_HISTORY, 1)),
+		key:     cfg.TRANSPORT_KEY,
+		peers:   strings.Fields(cfg.TRANSPORT_PEERS),
+		seen:    newIDIndex(),
+		srv:     cfg.TRANSPORT_PEERS_SRV,
+		streams: make(map[chan *message]bool),
+	}, nil
+}
+
+// Publish sends a message to the stream of every connected peer.
+// Messages are dropped for peers that are not keeping up.
+func (t *transportPeer) Publish(msg *message) (err error) {
+	t.see(msg.ID)
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	for stream := range t.streams {
+		select {
+		case stream <- msg:
+		default:
+			err = fmt.Errorf("Transport buffer full, message %s dropped", msg.ID)
+		}
+	}
+	return
+}
+
+// Run subscribes to the stream of every peer, reconnecting until the context is done.
+// Peers are discovered again periodically if an SRV record is configured.
+func (t *transportPeer) Run(ctx context.Context, fn func(*message)) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	follows := make(map[string]context.CancelFunc)
+	update := func(peers []string) {
+		for peer, cancel := range follows {
+			if !slices.Contains(peers, peer) {
+				cancel()
+				delete(follows, peer)
+			}
+		}
+		for _, peer := range peers {
+			if _, ok := follows[peer]; ok {
+				continue
+			}
+			peerCtx, cancel := context.WithCancel(ctx)
+			follows[peer] = cancel
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				t.follow(peerCtx, peer, fn)
+			}()
+		}
+	}
+	peers, _ := t.discover()
+	update(peers)
+	if len(t.srv) == 0 {
+		return
+	}
+	ticker := time.NewTicker(transportDiscoveryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if peers, ok := t.discover(); ok {
+				update(peers)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// discover returns the static peers along with the peers resolved from the SRV record.
+// Returns false if the SRV record cannot be resolved.
+// i.e. _mercure._tcp.example.com resolving to mercure-2.example.com:8001 adds http://mercure-2.example.com:8001
+func (t *transportPeer) discover() ([]string, bool) {
+	peers := slices.Clone(t.peers)
+	if len(t.srv) == 0 {
+		return peers, true
+	}
+	_, addrs, err := transportLookupSRV("", "", t.srv)
+	if err != nil {
+		log.Printf("Transport discovery failed: %v", err)
+		return peers, false
+	}
+	for _, addr := range addrs {
+		host := strings.TrimSuffix(addr.Target, ".")
+		peers = append(peers, "http://"+net.JoinHostPort(host, strconv.Itoa(int(addr.Port))))
+	}
+	return peers, true
+}
+
+// see records the ID of a message. Returns false if the ID was already seen.
+func (t *transportPeer) see(id string) bool {
+	n := t.seq.Add(1)
+	return t.seen.Add(id, n, n-min(n, t.history))
+}
+
+func (t *transportPeer) follow(ctx context.Context, peer string, fn func(*message)) {
+	for {
+		err := t.stream(ctx, peer, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Transport stream from %s closed: %v", peer, err)
+		select {
+		case <-time.After(transportRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *transportPeer) stream(ctx context.Context, peer string, fn func(*message)) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(peer, "/")+transportPath, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.key)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Unexpected status %d", resp.StatusCode)
+	}
+	for e, err := range sse.Read(resp.Body, &sse.ReadConfig{MaxEventSize: 16 << 20}) {
+		if err != nil {
+			return err
+		}
+		msg := &message{}
+		msg.FromJson([]byte(e.Data))
+		if len(msg.ID) > 0 && t.see(msg.ID) {
+			fn(msg)
+		}
+	}
+	return nil
+}
+
+// ServeHTTP serves the messages published to this node to an authenticated peer.
+func (t *transportPeer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+t.key)) != 1 {
+		writeUnauthorized(w, r)
+		return
+	}
+	stream := make(chan *message, 1024)
+	t.mutex.Lock()
+	t.streams[stream] = true
+	t.mutex.Unlock()
+	defer func() {
+		t.mutex.Lock()
+		delete(t.streams, stream)
+		t.mutex.Unlock()
+	}()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if _, err := w.Write([]byte(":\n")); err != nil {
+		return
+	}
+	flush := w.(http.Flusher).Flush
+	flush()
+	ping := time.NewTicker(pingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case msg := <-stream:
+			event := &message{ID: msg.ID, Data: string(msg.ToJson())}
+			if _, err := event.WriteTo(w); err != nil {
+				return
+			}
+			flush()
+		case <-ping.C:
+			if _, err := w.Write([]byte(":\n")); err != nil {
+				return
+			}
+			flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (t *transportPeer) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
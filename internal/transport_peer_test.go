@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportPeerLoop(t *testing.T) {
+	cfg := Config{TRANSPORT_KEY: "secret", TRANSPORT_HISTORY: 10}
+	a, err := newTransportPeer(cfg)
+	require.Nil(t, err)
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+	// The node follows itself and the peer follows the node under two addresses.
+	a.peers = []string{srv.URL}
+	b, err := newTransportPeer(cfg)
+	require.Nil(t, err)
+	b.peers = []string{srv.URL, srv.URL + "/"}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	var recvA, recvB atomic.Int64
+	go a.Run(ctx, func(*message) { recvA.Add(1) })
+	go b.Run(ctx, func(*message) { recvB.Add(1) })
+	time.Sleep(50 * time.Millisecond)
+	require.Nil(t, a.Publish(newMessage("", []string{"test"}, "1")))
+	require.Nil(t, a.Publish(newMessage("", []string{"test"}, "2")))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(0), recvA.Load())
+	assert.Equal(t, int64(2), recvB.Load())
+}
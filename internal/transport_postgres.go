@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"context"
+	"iter"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	transportPostgresPrune   = time.Minute
+	transportPostgresTimeout = 5 * time.Second
+)
+
+const transportPostgresChannel = "mercure_outbox"
+
+// transportPostgresSchema creates the outbox table. Each insert notifies the channel with the
+// sequence of the row when the inserting transaction commits.
+const transportPostgresSchema = `
+SELECT pg_advisory_xact_lock(hashtext('mercure_outbox'));
+CREATE TABLE IF NOT EXISTS mercure_outbox (
+	seq     bigserial PRIMARY KEY,
+	id      text NOT NULL UNIQUE DEFAULT 'urn:uuid:' || gen_random_uuid(),
+	type    text NOT NULL DEFAULT '',
+	topics  text[] NOT NULL,
+	data    text NOT NULL DEFAULT '',
+	private boolean NOT NULL DEFAULT false,
+	retain  boolean NOT NULL DEFAULT false,
+	retry   bigint NOT NULL DEFAULT 0,
+	expires timestamptz,
+	node    text NOT NULL DEFAULT ''
+);
+CREATE OR REPLACE FUNCTION mercure_outbox_notify() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify('mercure_outbox', NEW.seq::text);
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS mercure_outbox_notify ON mercure_outbox;
+CREATE TRIGGER mercure_outbox_notify AFTER INSERT ON mercure_outbox
+	FOR EACH ROW EXECUTE FUNCTION mercure_outbox_notify();`
+
+const transportPostgresSelect = `SELECT seq, id, type, topics, data, private, retain, retry, expires, node FROM mercure_outbox`
+
+// transportPostgres is a [Transport] reading messages from an outbox table in PostgreSQL.
+// Applications insert rows into mercure_outbox from within their own transactions so that a
+// message is broadcast only if the transaction commits:
+//
+//	INSERT INTO mercure_outbox (topics, data) VALUES ('{https://example.com/orders/1}', '{"status":"paid"}');
+//
+// Messages published to a node are inserted the same way. Every node listens for committed rows
+// and replays from the table, which is pruned to approximately the history size. Rows committed
+// out of sequence order while a listener is reconnecting are not delivered to that node.
+type transportPostgres struct {
+	history int64
+	node    string
+	pool    *pgxpool.Pool
+	url     string
+}
+
+func newTransportPostgres(cfg Config) (*transportPostgres, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), transportPostgresTimeout)
+	defer cancel()
+	pool, err := pgxpool.New(ctx, cfg.TRANSPORT_URL)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = pool.Exec(ctx, transportPostgresSchema); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return &transportPostgres{
+		history: int64(cfg.TRANSPORT_HISTORY),
+		node:    uuidv7(),
+		pool:    pool,
+		url:     cfg.TRANSPORT_URL,
+	}, nil
+}
+
+// Publish inserts a message into the outbox.
+func (t *transportPostgres) Publish(msg *message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), transportPostgresTimeout)
+	defer cancel()
+	var expires *time.Time
+	if !msg.Expires.IsZero() {
+		expires = &msg.Expires
+	}
+	_, err := t.pool.Exec(ctx, `INSERT INTO mercure_outbox (id, type, topics, data, private, retain, retry, expires, node)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		msg.ID, msg.Type, msg.Topics, msg.Data, msg.Private, msg.Retain, int64(msg.Retry), expires, t.node)
+	return err
+}
+
+// Run listens for rows committed to the outbox, reconnecting until the context is done.
+// Rows inserted by other nodes and by applications are passed to fn.
+func (t *transportPostgres) Run(ctx context.Context, fn func(*message)) {
+	go t.prune(ctx)
+	last := int64(-1)
+	for {
+		err := t.listen(ctx, &last, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Transport listener closed: %v", err)
+		select {
+		case <-time.After(transportRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *transportPostgres) listen(ctx context.Context, last *int64, fn func(*message)) error {
+	conn, err := pgx.Connect(ctx, t.url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err = conn.Exec(ctx, "LISTEN "+transportPostgresChannel); err != nil {
+		return err
+	}
+	if *last < 0 {
+		err = conn.QueryRow(ctx, `SELECT coalesce(max(seq), 0) FROM mercure_outbox`).Scan(last)
+	} else {
+		// Deliver rows committed while reconnecting.
+		err = t.deliver(ctx, conn, transportPostgresSelect+` WHERE seq > $1 ORDER BY seq`, *last, last, fn)
+	}
+	if err != nil {
+		return err
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		seq, err := strconv.ParseInt(n.Payload, 10, 64)
+		if err != nil {
+			continue
+		}
+		if err = t.deliver(ctx, conn, transportPostgresSelect+` WHERE seq = $1`, seq, last, fn); err != nil {
+			return err
+		}
+	}
+}
+
+func (t *transportPostgres) deliver(ctx context.Context, conn *pgx.Conn, sql string, seq int64, last *int64, fn func(*message)) error {
+	rows, err := conn.Query(ctx, sql, seq)
+	if err != nil {
+		return err
+	}
+	var msgs []*message
+	for rows.Next() {
+		seq, node, msg, err := transportPostgresScan(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		*last = max(*last, seq)
+		if node != t.node {
+			msgs = append(msgs, msg)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		fn(msg)
+	}
+	return nil
+}
+
+// prune deletes rows beyond the history size periodically until the context is done.
+func (t *transportPostgres) prune(ctx context.Context) {
+	ticker := time.NewTicker(transportPostgresPrune)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_, err := t.pool.Exec(ctx, `DELETE FROM mercure_outbox
+				WHERE seq <= (SELECT max(seq) FROM mercure_outbox) - $1`, t.history)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Transport prune failed: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Replay reads the rows inserted after the row with the given ID from the outbox.
+func (t *transportPostgres) Replay(topics []string, id string) (iter.Seq[*message], bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), transportPostgresTimeout)
+	defer cancel()
+	var seq int64
+	if id != lastEventEarliest {
+		err := t.pool.QueryRow(ctx, `SELECT seq FROM mercure_outbox WHERE id = $1`, id).Scan(&seq)
+		if err != nil {
+			return nil, false
+		}
+	}
+	rows, err := t.pool.Query(ctx, transportPostgresSelect+` WHERE seq > $1 ORDER BY seq`, seq)
+	if err != nil {
+		return nil, false
+	}
+	defer rows.Close()
+	matcher := newTopicMatcher(topics)
+	var msgs []*message
+	for rows.Next() {
+		_, _, msg, err := transportPostgresScan(rows)
+		if err != nil {
+			return nil, false
+		}
+		if matcher.Match(msg.Topics...) {
+			msgs = append(msgs, msg)
+		}
+	}
+	if rows.Err() != nil {
+		return nil, false
+	}
+	return slices.Values(msgs), true
+}
+
+func transportPostgresScan(row pgx.Row) (seq int64, node string, msg *message, err error) {
+	var retry int64
+	var expires *time.Time
+	msg = &message{}
+	err = row.Scan(&seq, &msg.ID, &msg.Type, &msg.Topics, &msg.Data, &msg.Private, &msg.Retain, &retry, &expires, &node)
+	msg.Retry = uint64(max(retry, 0))
+	if expires != nil {
+		msg.Expires = *expires
+	}
+	return
+}
+
+func (t *transportPostgres) Close() error {
+	t.pool.Close()
+	return nil
+}
@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	transportRedisKey     = "mercure-lite"
+	transportRedisBatch   = int64(1000)
+	transportRedisTimeout = 5 * time.Second
+)
+
+// transportRedis is a [Transport] fanning out messages through Redis pub/sub. Each message is
+// also appended to a Redis stream capped at the history size from which any node can replay.
+type transportRedis struct {
+	client  *redis.Client
+	history int64
+	node    string
+}
+
+// transportEnvelope wraps a message with the node to which it was published so that nodes can
+// ignore their own messages.
+type transportEnvelope struct {
+	Node    string          `json:"node"`
+	Message json.RawMessage `json:"message"`
+}
+
+func newTransportRedis(cfg Config) (*transportRedis, error) {
+	opts, err := redis.ParseURL(cfg.TRANSPORT_URL)
+	if err != nil {
+		return nil, err
+	}
+	return &transportRedis{
+		client:  redis.NewClient(opts),
+		history: int64(cfg.TRANSPORT_HISTORY),
+		node:    uuidv7(),
+	}, nil
+}
+
+// Publish appends a message to the stream and publishes it to the channel.
+func (t *transportRedis) Publish(msg *message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), transportRedisTimeout)
+	defer cancel()
+	data := msg.ToJson()
+	env, _ := json.Marshal(transportEnvelope{t.node, data})
+	_, err := t.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.XAdd(ctx, &redis.XAddArgs{
+			Stream: transportRedisKey,
+			MaxLen: t.history,
+			Approx: true,
+			Values: map[string]any{"message": data},
+		})
+		p.Publish(ctx, transportRedisKey, env)
+		return nil
+	})
+	return err
+}
+
+// Run subscribes to the channel. The client resubscribes automatically after connection loss.
+func (t *transportRedis) Run(ctx context.Context, fn func(*message)) {
+	sub := t.client.Subscribe(ctx, transportRedisKey)
+	defer sub.Close()
+	ch := sub.Channel()
+	for {
+		select {
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var env transportEnvelope
+			if json.Unmarshal([]byte(m.Payload), &env) != nil || env.Node == t.node {
+				continue
+			}
+			msg := &message{}
+			msg.FromJson(env.Message)
+			if len(msg.ID) > 0 {
+				fn(msg)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Replay scans the stream backward from the newest message until the message with the given ID
+// is found. Clients are expected to resume from recent messages.
+func (t *transportRedis) Replay(topics []string, id string) (iter.Seq[*message], bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), transportRedisTimeout)
+	defer cancel()
+	matcher := newTopicMatcher(topics)
+	var msgs []*message
+	for end := "+"; ; {
+		entries, err := t.client.XRevRangeN(ctx, transportRedisKey, end, "-", transportRedisBatch).Result()
+		if err != nil || len(entries) == 0 {
+			break
+		}
+		for _, e := range entries {
+			data, _ := e.Values["message"].(string)
+			msg := &message{}
+			msg.FromJson([]byte(data))
+			if msg.ID == id {
+				slices.Reverse(msgs)
+				return slices.Values(msgs), true
+			}
+			if matcher.Match(msg.Topics...) {
+				msgs = append(msgs, msg)
+			}
+		}
+		end = "(" + entries[len(entries)-1].ID
+	}
+	if id == lastEventEarliest {
+		slices.Reverse(msgs)
+		return slices.Values(msgs), true
+	}
+	return nil, false
+}
+
+func (t *transportRedis) Close() error {
+	return t.client.Close()
+}
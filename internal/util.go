@@ -2,6 +2,8 @@ package internal
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gofrs/uuid/v5"
 
@@ -32,3 +34,19 @@ func msgIDtimestamp(id string) uint64 {
 	}
 	return uint64(t)
 }
+
+// timeCursor returns the cursor of a message published at a point in time.
+func timeCursor(t time.Time) uint64 {
+	u, _ := uuid.NewV7AtTime(t)
+	ts, _ := uuid.TimestampFromV7(u)
+	return uint64(ts)
+}
+
+// lastEventEarliest is the reserved Last-Event-ID requesting all retained history.
+const lastEventEarliest = "earliest"
+
+// validID indicates whether a publisher supplied ID can be written to an event stream.
+// The reserved Last-Event-ID value "earliest" is not a valid ID.
+func validID(id string) bool {
+	return id != lastEventEarliest && !strings.ContainsAny(id, "\r\n\x00")
+}